@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Ishan27g/go-utils/tracing"
 	"github.com/Ishan27g/internalApi/request"
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 )
 
 /*
@@ -41,9 +45,25 @@ type NamedApi struct {
 	tracingProvider tracing.TraceProvider
 	httpClient      *http.Client
 	defaultHeaders  map[string]string
-
+	interceptors    []Interceptor
+	cache           Cache
+	maxBodySize     int64
+	recorder        *recorder
+	har             *HARRecorder
+	mocks           *mocks
+	faults          *faults
+	metrics         Metrics
+	logger          Logger
+	grpcDialOptions []grpc.DialOption
+
+	basePath         string // version by default
+	tracingTransport bool
+
+	lock      sync.RWMutex          // guards the registry, swapped by ApplyConfig
+	config    *Config               // applied by ApplyConfig
 	hosts     host                  // host: apiNames...
 	apiClient map[string]*apiClient // host : apiClient{apiNames...}
+	apis      map[string]*apiConfig // apiName : apiConfig
 
 }
 type host map[string][]string
@@ -63,11 +83,15 @@ func (h *host) getHost(apiName string) string {
 func NewNamed(version string, options ...Option) *NamedApi {
 	api := &NamedApi{
 		version:         version,
+		basePath:        basePath(version),
 		tracingProvider: nil,
 		httpClient:      nil,
 
 		hosts:     host{},
 		apiClient: map[string]*apiClient{},
+		apis:      map[string]*apiConfig{},
+		mocks:     newMocks(),
+		faults:    newFaults(),
 
 		defaultHeaders: map[string]string{
 			//"X-CUSTOM-HEADER": "custom",
@@ -82,11 +106,41 @@ func NewNamed(version string, options ...Option) *NamedApi {
 	if api.httpClient == nil {
 		WithDefaultHttpClient()(api)
 	}
+	if api.logger == nil {
+		api.logger = NewLogrusLogger(log.StandardLogger())
+	}
 
 	return api
 }
 
-func (na *NamedApi) Add(apiName string, host string) {
+// Add a named api endpoint served by host. Optional ApiOption(s) register the
+// method, path and default headers for this apiName
+func (na *NamedApi) Add(apiName string, host string, options ...ApiOption) {
+	config := newApiConfig(apiName, options...)
+	na.lock.Lock()
+	defer na.lock.Unlock()
+	na.add(apiName, host, config)
+}
+
+func newApiConfig(apiName string, options ...ApiOption) *apiConfig {
+	config := &apiConfig{headers: map[string]string{}}
+	for _, option := range options {
+		option(config)
+	}
+	if config.resolver != nil {
+		config.pool = newEndpointPool(apiName, config)
+	}
+	return config
+}
+
+// add the apiName to host, called with the lock held
+func (na *NamedApi) add(apiName string, host string, config *apiConfig) {
+	// adding an apiName again replaces it, its hooks move to the new host
+	hook, grpcHook := na.unlink(apiName)
+	na.apis[apiName] = config
+
+	// scheme can be set as part of the host, eg. https://localhost:9443
+	scheme, host := splitScheme(host)
 
 	// add apiName to host
 	if na.hosts[host] == nil {
@@ -95,28 +149,135 @@ func (na *NamedApi) Add(apiName string, host string) {
 	na.hosts[host] = append(na.hosts[host], apiName)
 
 	// add NamedApi client for this host
+	client := na.client(host)
+	if scheme != "" {
+		client.scheme = scheme
+	}
+	if hook != nil {
+		client.hooks[apiName] = hook
+	}
+	if grpcHook != nil {
+		client.grpcHooks[apiName] = grpcHook
+	}
+}
+
+// Update replaces the host and ApiOption(s) of an added apiName, requests in flight complete
+// with the previous ones
+func (na *NamedApi) Update(apiName string, host string, options ...ApiOption) error {
+	config := newApiConfig(apiName, options...)
+	na.lock.Lock()
+	defer na.lock.Unlock()
+	if _, ok := na.apis[apiName]; !ok {
+		return errors.New(fmt.Sprintf("%s NamedApi not added", apiName))
+	}
+	na.add(apiName, host, config)
+	return nil
+}
+
+// Remove the apiName along with its hooks and expectations. Hosts and their settings
+// (AddHost) are kept
+func (na *NamedApi) Remove(apiName string) {
+	na.lock.Lock()
+	defer na.lock.Unlock()
+	na.unlink(apiName)
+	delete(na.apis, apiName)
+
+	na.mocks.lock.Lock()
+	defer na.mocks.lock.Unlock()
+	delete(na.mocks.expectations, apiName)
+}
+
+// remove apiName from its host, returning its hooks. Called with the lock held
+func (na *NamedApi) unlink(apiName string) (*HttpHook, *GrpcHook) {
+	host := na.hosts.getHost(apiName)
+	if host == "" {
+		return nil, nil
+	}
+	var apiNames []string
+	for _, name := range na.hosts[host] {
+		if name != apiName {
+			apiNames = append(apiNames, name)
+		}
+	}
+	if len(apiNames) == 0 {
+		delete(na.hosts, host)
+	} else {
+		na.hosts[host] = apiNames
+	}
+
+	client := na.apiClient[host]
+	hook, grpcHook := client.hooks[apiName], client.grpcHooks[apiName]
+	delete(client.hooks, apiName)
+	delete(client.grpcHooks, apiName)
+	return hook, grpcHook
+}
+
+// ApiInfo describes an added apiName, see NamedApi.Apis
+type ApiInfo struct {
+	Name       string
+	Host       string // scheme://host
+	Route      string // eg. GET /users/{id}, the method is empty unless set by the ApiOption(s)
+	GrpcMethod string
+	Hooked     bool
+}
+
+// Apis lists the added apiNames with their hosts, ordered by name
+func (na *NamedApi) Apis() []ApiInfo {
+	na.lock.RLock()
+	defer na.lock.RUnlock()
+	var apis []ApiInfo
+	for host, apiNames := range na.hosts {
+		client := na.apiClient[host]
+		for _, apiName := range apiNames {
+			config := na.apis[apiName]
+			apis = append(apis, ApiInfo{
+				Name:       apiName,
+				Host:       client.scheme + "://" + host,
+				Route:      strings.TrimSpace(config.method + " " + config.path),
+				GrpcMethod: config.grpcMethod,
+				Hooked:     client.hooks[apiName] != nil || client.grpcHooks[apiName] != nil,
+			})
+		}
+	}
+	sort.Slice(apis, func(i, j int) bool {
+		return apis[i].Name < apis[j].Name
+	})
+	return apis
+}
+
+// get or add the NamedApi client for this host
+func (na *NamedApi) client(host string) *apiClient {
 	if na.apiClient[host] == nil {
 		na.apiClient[host] = &apiClient{
+			scheme:          "http",
 			tracingProvider: na.tracingProvider,
+			logger:          na.logger,
 			httpClient:      *na.httpClient,
 			hooks:           map[string]*HttpHook{},
+			grpcHooks:       map[string]*GrpcHook{},
 		}
 	}
-
+	return na.apiClient[host]
 }
 func (na *NamedApi) ExpectHook(apiName string, handler HttpHook) {
+	if err := na.setHook(apiName, handler); err != nil {
+		panic(err.Error())
+	}
+}
 
+// setHook is ExpectHook returning an error, for hooks loaded from files
+func (na *NamedApi) setHook(apiName string, handler HttpHook) error {
+	na.lock.Lock()
+	defer na.lock.Unlock()
 	host := na.hosts.getHost(apiName)
 	if host == "" {
-		panic("expecting an api hook which was not added " + apiName)
+		return errors.New("expecting an api hook which was not added " + apiName)
 	}
-
 	if na.apiClient[host].hooks[apiName] != nil {
-		panic("already added hook " + apiName)
+		return errors.New("already added hook " + apiName)
 	}
-
 	na.apiClient[host].hooks[apiName] = &handler
-
+	return nil
 }
 
 func (na *NamedApi) Get(apiName string, req *request.Request) ([]byte, error) {
@@ -138,12 +299,83 @@ func (na *NamedApi) Options(apiName string, req *request.Request) ([]byte, error
 	return na.do(apiName, http.MethodOptions, req)
 }
 
+// Call sends the request with the method registered for this apiName, defaults to GET
+func (na *NamedApi) Call(apiName string, req *request.Request) ([]byte, error) {
+	method := http.MethodGet
+	na.lock.RLock()
+	if config := na.apis[apiName]; config != nil && config.method != "" {
+		method = config.method
+	}
+	na.lock.RUnlock()
+	return na.do(apiName, method, req)
+}
+
 // does bulk of http request preparation before calling the client for this host
 func (na *NamedApi) do(apiName string, method string, r *request.Request) (rsp []byte, err error) {
 
+	resp, done, err := na.send(apiName, method, r, false)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		done(err)
+	}()
+
+	return readBody(withTrace(resp.Request.Context(), na.logger), resp, na.maxBodySizeFor(apiName))
+}
+
+// base path added to requests sent to host, set by WithHostBasePath or the NamedApi's
+func (na *NamedApi) basePathFor(host string) string {
+	_, address := splitScheme(host)
+	na.lock.RLock()
+	defer na.lock.RUnlock()
+	if client := na.apiClient[address]; client != nil && client.basePath != nil {
+		return *client.basePath
+	}
+	return na.basePath
+}
+
+// max body size of the apiName, or the NamedApi's
+func (na *NamedApi) maxBodySizeFor(apiName string) int64 {
+	na.lock.RLock()
+	defer na.lock.RUnlock()
+	if config := na.apis[apiName]; config != nil && config.maxBodySize > 0 {
+		return config.maxBodySize
+	}
+	return na.maxBodySize
+}
+
+// send prepares the request and sends it via the client for this host. On success, done
+// must be called with the outcome once the response body is consumed, it ends the span
+// and releases the request's resources. Streamed responses skip the interceptors that read
+// the whole response body: validation, the cache and the cassette recorder
+func (na *NamedApi) send(apiName string, method string, r *request.Request, stream bool) (resp *http.Response, done func(err error), err error) {
+
+	var cleanup []func(err error)
+	release := func(err error) {
+		for i := len(cleanup) - 1; i >= 0; i-- {
+			cleanup[i](err)
+		}
+	}
+	defer func() {
+		if err != nil {
+			release(err)
+		}
+	}()
+
+	na.lock.RLock()
 	host := na.hosts.getHost(apiName)
-	if na.apiClient[host] == nil {
-		return nil, errors.New(fmt.Sprintf("%s NamedApi not added", apiName))
+	client := na.apiClient[host]
+	config := na.apis[apiName]
+	base, defaultHeaders := na.basePath, na.defaultHeaders
+	var hook *HttpHook
+	if client != nil {
+		// AddHost may change the client's settings while the request is in flight
+		hook, client = client.hooks[apiName], client.snapshot()
+	}
+	na.lock.RUnlock()
+	if client == nil {
+		return nil, nil, errors.New(fmt.Sprintf("%s NamedApi not added", apiName))
 	}
 
 	var (
@@ -155,13 +387,82 @@ func (na *NamedApi) do(apiName string, method string, r *request.Request) (rsp [
 	if na.tracingProvider != nil {
 		_, span = na.tracingProvider.Get().Start(r.Request.Context(), apiName)
 	}
-	defer span.End()
+	cleanup = append(cleanup, func(error) {
+		span.End()
+	})
 
 	ctx = trace.ContextWithSpan(r.Request.Context(), span)
 
-	doRequest, err = http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://%s/%s%s", host, na.version, r.Request.URL.String()), r.Request.Body)
+	if config == nil {
+		config = &apiConfig{headers: map[string]string{}}
+	}
+	if config.err != nil {
+		return nil, nil, fmt.Errorf("%s NamedApi : %v", apiName, config.err)
+	}
+
+	// per call timeout overrides the apiName's timeout
+	timeout := config.timeout
+	if r.Timeout() > 0 {
+		timeout = r.Timeout()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		cleanup = append(cleanup, func(error) {
+			cancel()
+		})
+	}
+
+	target := host
+	if config.pool != nil {
+		e, pickErr := config.pool.pick()
+		if pickErr != nil {
+			return nil, nil, fmt.Errorf("%s NamedApi no endpoints : %v", apiName, pickErr)
+		}
+		target = e.host
+		cleanup = append(cleanup, func(err error) {
+			config.pool.done(e, isEndpointFailure(err))
+		})
+	}
+
+	if client.basePath != nil {
+		base = *client.basePath
+	}
+
+	endpoint := r.Request.URL.String()
+	if r.Request.URL.Path == "" && config.path != "" {
+		path, pathErr := expandRoute(config.path, config.params, r.PathParams())
+		if pathErr != nil {
+			return nil, nil, fmt.Errorf("%s NamedApi : %w", apiName, pathErr)
+		}
+		u := *r.Request.URL
+		u.Path, u.RawPath = "", ""
+		endpoint = path + u.String()
+
+		// spans are named after the route rather than the url
+		ctx = withRoute(ctx, base+config.path)
+	} else if len(r.PathParams()) > 0 {
+		return nil, nil, fmt.Errorf("%s NamedApi : %w : request has a path", apiName, ErrPathParams)
+	}
+
+	doRequest, err = http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s://%s%s%s", client.scheme, target, base, endpoint), r.Request.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	// replayable bodies stay replayable, for retries
+	if r.Request.GetBody != nil {
+		doRequest.GetBody, doRequest.ContentLength = r.Request.GetBody, r.Request.ContentLength
+	}
+
+	// default query params
+	if len(config.query) > 0 {
+		query := doRequest.URL.Query()
+		for key, vals := range config.query {
+			if _, ok := query[key]; !ok {
+				query[key] = vals
+			}
+		}
+		doRequest.URL.RawQuery = query.Encode()
 	}
 
 	// copy optional headers
@@ -172,24 +473,53 @@ func (na *NamedApi) do(apiName string, method string, r *request.Request) (rsp [
 		}
 	}
 
-	// add default headers
-	for key, val := range na.defaultHeaders {
-		if doRequest.Header.Get(key) == "" {
-			doRequest.Header.Set(key, val)
-			span.SetAttributes(attribute.Key("internal-header" + key).String(val))
-		}
+	// propagate the deadline to the downstream host
+	if deadline, ok := ctx.Deadline(); ok {
+		setDeadlineHeader(doRequest.Header, deadline)
+		span.SetAttributes(attribute.Key("request-timeout").String(time.Until(deadline).String()))
 	}
 
-	// post,put,patch
-	if strings.Contains(doRequest.Method, "P") && doRequest.Header.Get("Content-Type") == "" {
-		doRequest.Header.Set("Content-Type", "application/json")
+	interceptors := []Interceptor{withHeaders(config.headers, false), withHeaders(defaultHeaders, true), withContentType}
+	interceptors = append(interceptors, na.interceptors...)
+	interceptors = append(interceptors, config.interceptors...)
+	if config.validation != nil && !stream {
+		interceptors = append(interceptors, withValidation(na.logger, apiName, config.validation, na.maxBodySizeFor(apiName)))
 	}
+	cache := na.cache
+	if config.cache != nil {
+		cache = config.cache
+	}
+	if cache != nil && !stream {
+		interceptors = append(interceptors, withCache(cache, na.maxBodySizeFor(apiName)))
+	}
+	if config.retry != nil {
+		interceptors = append(interceptors, config.retry.interceptor)
+	}
+	if config.limiter != nil {
+		interceptors = append(interceptors, config.limiter.interceptor)
+	}
+	if client.limiter != nil {
+		interceptors = append(interceptors, client.limiter.interceptor)
+	}
+	if na.metrics != nil {
+		interceptors = append(interceptors, withMetrics(na.metrics, apiName))
+	}
+	interceptors = append(interceptors, na.faults.interceptor(apiName))
+	if na.recorder != nil && !stream {
+		interceptors = append(interceptors, na.recorder.interceptor(apiName, na.maxBodySizeFor(apiName)))
+	}
+	if na.har != nil {
+		interceptors = append(interceptors, na.har.interceptor(apiName))
+	}
+	if config.compression != nil {
+		interceptors = append(interceptors, config.compression.interceptor)
+	}
+	interceptors = append(interceptors, withLogging(na.logger))
 
-	log.WithFields(log.Fields{
-		"url":    doRequest.URL.String(),
-		"method": doRequest.Method,
-	}).Debug("sending request")
-
-	return na.apiClient[host].do(ctx, na.apiClient[host].hooks[apiName], doRequest)
+	resp, err = client.do(ctx, hook, doRequest, interceptors...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, release, nil
 
 }
@@ -105,3 +105,104 @@ func Test_Api_AsHook(t *testing.T) {
 	assert.Equal(t, "mock-user", user.Name)
 
 }
+
+func Test_Api_Registry(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("GetUser", "localhost:9999", WithRoute("GET /users/{id}"))
+	api.Add("Health", "https://localhost:9443", WithGrpcMethod("grpc.health.v1.Health/Check"))
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	})
+
+	assert.Equal(t, []ApiInfo{
+		{Name: "GetUser", Host: "http://localhost:9999", Route: "GET /users/{id}", Hooked: true},
+		{Name: "Health", Host: "https://localhost:9443", GrpcMethod: "/grpc.health.v1.Health/Check"},
+	}, api.Apis())
+
+	get := func() (string, error) {
+		req, _ := request.NewRequest(context.Background(), "", nil, request.WithPathParams(map[string]string{"id": "1"}))
+		b, err := api.Call("GetUser", req)
+		return string(b), err
+	}
+	host, err := get()
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:9999", host)
+
+	// re-pointed, the hook moves to the new host
+	assert.NoError(t, api.Update("GetUser", "localhost:8888", WithRoute("GET /accounts/{id}")))
+	host, err = get()
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:8888", host)
+	assert.Equal(t, "GET /accounts/{id}", api.Apis()[0].Route)
+	assert.Error(t, api.Update("Other", "localhost:8888"))
+
+	api.Remove("GetUser")
+	_, err = get()
+	assert.Error(t, err)
+	assert.Len(t, api.Apis(), 1)
+
+	// concurrent registration while requests are in flight
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			name := fmt.Sprintf("Api-%d", i)
+			api.Add(name, "localhost:9999")
+			api.ExpectHook(name, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			api.Remove(name)
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		req, _ := request.NewRequest(context.Background(), "/", nil)
+		_, _ = api.Call(fmt.Sprintf("Api-%d", i), req)
+		_ = api.Apis()
+	}
+	<-done
+	assert.Len(t, api.Apis(), 1)
+
+	// host settings change while requests are in flight
+	api.Add("GetUser", "localhost:9999")
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	stop := make(chan bool)
+	done = make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				assert.NoError(t, api.AddHost("localhost:9999", WithHostBasePath(fmt.Sprintf("v%d", i)), WithScheme("http")))
+			}
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		req, _ := request.NewRequest(context.Background(), "/", nil)
+		_, err := api.Call("GetUser", req)
+		assert.NoError(t, err)
+	}
+	close(stop)
+	<-done
+}
+
+func Test_Api_UpdateRemoved(t *testing.T) {
+	api := NewNamed("v1")
+	for i := 0; i < 50; i++ {
+		api.Add("GetUser", "localhost:9999")
+		done := make(chan bool)
+		go func() {
+			defer close(done)
+			api.Remove("GetUser")
+		}()
+		_ = api.Update("GetUser", "localhost:8888")
+		<-done
+		api.Remove("GetUser")
+		// an update never re-creates a removed api
+		assert.Error(t, api.Update("GetUser", "localhost:8888"))
+		assert.Empty(t, api.Apis())
+	}
+}
@@ -0,0 +1,194 @@
+package internalApi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultExpiryDelta refreshes cached tokens before they expire
+const defaultExpiryDelta = 10 * time.Second
+
+// tokenClient fetches tokens unless ClientCredentials.HttpClient is set
+var tokenClient = &http.Client{Timeout: 30 * time.Second}
+
+// BearerAuth sets a static bearer token on requests without an Authorization header
+func BearerAuth(token string) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") == "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			return next(req)
+		}
+	}
+}
+
+// ApiKeyAuth sets the key in the header on requests without it, eg. X-API-Key
+func ApiKeyAuth(header, key string) Interceptor {
+	return withHeaders(map[string]string{header: key}, false)
+}
+
+// ClientCredentials fetches tokens using the OAuth2 client credentials grant. Tokens are
+// cached and refreshed ExpiryDelta before they expire
+type ClientCredentials struct {
+	TokenURL       string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	EndpointParams url.Values
+
+	// defaults to 10 seconds
+	ExpiryDelta time.Duration
+	// defaults to a client with a 30 seconds timeout
+	HttpClient *http.Client
+
+	lock   sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Token returns the cached token or fetches a new one
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delta := c.ExpiryDelta
+	if delta == 0 {
+		delta = defaultExpiryDelta
+	}
+	if c.token != "" && (c.expiry.IsZero() || time.Now().Add(delta).Before(c.expiry)) {
+		return c.token, nil
+	}
+
+	token, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token = token.AccessToken
+	c.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		c.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return c.token, nil
+}
+
+// Invalidate drops the cached token, the next request fetches a new one
+func (c *ClientCredentials) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.token = ""
+}
+
+// InvalidateToken drops the cached token if it is still token, eg. once it was rejected.
+// A token fetched since by another request is kept
+func (c *ClientCredentials) InvalidateToken(token string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+func (c *ClientCredentials) fetch(ctx context.Context) (*tokenResponse, error) {
+	form := url.Values{}
+	for key, val := range c.EndpointParams {
+		form[key] = val
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	client := c.HttpClient
+	if client == nil {
+		client = tokenClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching token : %v", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading token : %v", err)
+	}
+
+	var token tokenResponse
+	_ = json.Unmarshal(b, &token)
+	if token.Error != "" {
+		return nil, fmt.Errorf("error fetching token : %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching token : %s", resp.Status)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("error fetching token : missing access_token")
+	}
+	return &token, nil
+}
+
+// OAuth2ClientCredentials sets the bearer token from c on requests without an
+// Authorization header. A 401 response drops the rejected token, the request is sent once
+// more with a new token if its body can be replayed
+func OAuth2ClientCredentials(c *ClientCredentials) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") != "" {
+				return next(req)
+			}
+			token, err := c.Token(req.Context())
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			resp, err := next(req)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+			c.InvalidateToken(token)
+
+			replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+			if !replayable {
+				return resp, nil
+			}
+			_ = resp.Body.Close()
+			if token, err = c.Token(req.Context()); err != nil {
+				return nil, err
+			}
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			return next(req)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package internalApi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func tokenServer(t *testing.T, expiresIn int64, fetched *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		assert.NoError(t, r.ParseForm())
+		if id != "client" || secret != "secret" || r.Form.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		n := atomic.AddInt64(fetched, 1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+}
+
+func Test_Auth_ClientCredentials(t *testing.T) {
+	var fetched int64
+	ts := tokenServer(t, 3600, &fetched)
+	defer ts.Close()
+
+	credentials := &ClientCredentials{TokenURL: ts.URL, ClientID: "client", ClientSecret: "secret", Scopes: []string{"users"}}
+	api := NewNamed("v1")
+	api.Add("GetUser", "localhost:9999", WithApiInterceptors(OAuth2ClientCredentials(credentials)))
+
+	var auth, body string
+	revoked := map[string]bool{}
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.Body != nil {
+			b, _ := ioutil.ReadAll(r.Body)
+			body = string(b)
+		}
+		if revoked[auth] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for i := 0; i < 3; i++ {
+		req, _ := request.NewRequest(context.Background(), "/users", nil)
+		_, err := api.Get("GetUser", req)
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer token-1", auth)
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&fetched))
+
+	// unauthorized drops the cached token, the request is sent again with a new one
+	revoked["Bearer token-1"] = true
+	req, _ := request.NewRequest(context.Background(), "/users", strings.NewReader("user"))
+	_, err := api.Post("GetUser", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer token-2", auth)
+	assert.Equal(t, "user", body)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&fetched))
+
+	// once
+	revoked["Bearer token-2"], revoked["Bearer token-3"] = true, true
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Get("GetUser", req)
+	assert.Error(t, err)
+	assert.Equal(t, int64(3), atomic.LoadInt64(&fetched))
+
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Get("GetUser", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer token-4", auth)
+}
+
+func Test_Auth_Refresh(t *testing.T) {
+	var fetched int64
+	ts := tokenServer(t, 1, &fetched)
+	defer ts.Close()
+
+	credentials := &ClientCredentials{TokenURL: ts.URL, ClientID: "client", ClientSecret: "secret", ExpiryDelta: 500 * time.Millisecond}
+	token, err := credentials.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	token, _ = credentials.Token(context.Background())
+	assert.Equal(t, "token-1", token)
+
+	<-time.After(600 * time.Millisecond)
+	token, _ = credentials.Token(context.Background())
+	assert.Equal(t, "token-2", token)
+
+	_, err = (&ClientCredentials{TokenURL: ts.URL, ClientID: "client", ClientSecret: "wrong"}).Token(context.Background())
+	assert.ErrorContains(t, err, "invalid_client")
+}
+
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func Test_Auth_Unauthorized(t *testing.T) {
+	var fetched int64
+	ts := tokenServer(t, 3600, &fetched)
+	credentials := &ClientCredentials{TokenURL: ts.URL, ClientID: "client", ClientSecret: "secret"}
+
+	// a token fetched since the rejected one is kept
+	token, err := credentials.Token(context.Background())
+	assert.NoError(t, err)
+	credentials.Invalidate()
+	newer, _ := credentials.Token(context.Background())
+	credentials.InvalidateToken(token)
+	token, _ = credentials.Token(context.Background())
+	assert.Equal(t, newer, token)
+	credentials.InvalidateToken(token)
+	token, _ = credentials.Token(context.Background())
+	assert.Equal(t, "token-3", token)
+
+	// the 401 body is closed when a new token can't be fetched
+	ts.Close()
+	body := &closeTracker{Reader: strings.NewReader("unauthorized")}
+	send := OAuth2ClientCredentials(credentials)(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusUnauthorized, Body: body}, nil
+	})
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:9999/users", nil)
+	_, err = send(req)
+	assert.Error(t, err)
+	assert.True(t, body.closed)
+}
+
+func Test_Auth_Static(t *testing.T) {
+	api := NewNamed("v1", WithInterceptors(BearerAuth("static"), ApiKeyAuth("X-API-Key", "key")))
+	api.Add("GetUser", "localhost:9999")
+
+	var headers http.Header
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Get("GetUser", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer static", headers.Get("Authorization"))
+	assert.Equal(t, "key", headers.Get("X-API-Key"))
+
+	// per request auth wins
+	req, _ = request.NewRequest(context.Background(), "/users", nil, request.WithBearerToken("mine"), request.WithApiKey("X-API-Key", "my-key"))
+	_, err = api.Get("GetUser", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer mine", headers.Get("Authorization"))
+	assert.Equal(t, "my-key", headers.Get("X-API-Key"))
+}
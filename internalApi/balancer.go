@@ -0,0 +1,131 @@
+package internalApi
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancer selects the endpoint for the next request
+type Balancer int
+
+const (
+	RoundRobin Balancer = iota
+	LeastInflight
+	Random
+)
+
+const (
+	defaultEjectFailures = 3
+	defaultEjectDuration = 30 * time.Second
+)
+
+type endpoint struct {
+	host     string
+	inflight int64
+
+	failures     int
+	ejectedUntil time.Time
+}
+
+// endpointPool balances requests for an apiName across the endpoints of its Resolver.
+// Endpoints failing consecutively are ejected for a while (passive health checking)
+type endpointPool struct {
+	apiName  string
+	resolver Resolver
+	balancer Balancer
+
+	ejectFailures int
+	ejectDuration time.Duration
+
+	lock      sync.Mutex
+	endpoints map[string]*endpoint // host : endpoint
+	next      uint64
+}
+
+func newEndpointPool(apiName string, config *apiConfig) *endpointPool {
+	p := &endpointPool{
+		apiName:       apiName,
+		resolver:      config.resolver,
+		balancer:      config.balancer,
+		ejectFailures: config.ejectFailures,
+		ejectDuration: config.ejectDuration,
+		endpoints:     map[string]*endpoint{},
+	}
+	if p.ejectFailures <= 0 {
+		p.ejectFailures = defaultEjectFailures
+	}
+	if p.ejectDuration <= 0 {
+		p.ejectDuration = defaultEjectDuration
+	}
+	return p
+}
+
+// pick an endpoint and mark it in-flight, caller must call done
+func (p *endpointPool) pick() (*endpoint, error) {
+	hosts, err := p.resolver.Resolve(p.apiName)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("no endpoints")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	var healthy, all []*endpoint
+	for _, host := range hosts {
+		e := p.endpoints[host]
+		if e == nil {
+			e = &endpoint{host: host}
+			p.endpoints[host] = e
+		}
+		all = append(all, e)
+		if now.After(e.ejectedUntil) {
+			healthy = append(healthy, e)
+		}
+	}
+	// if every endpoint is ejected, try them all rather than failing
+	if len(healthy) == 0 {
+		healthy = all
+	}
+
+	var e *endpoint
+	switch p.balancer {
+	case LeastInflight:
+		for _, candidate := range healthy {
+			if e == nil || atomic.LoadInt64(&candidate.inflight) < atomic.LoadInt64(&e.inflight) {
+				e = candidate
+			}
+		}
+	case Random:
+		e = healthy[rand.Intn(len(healthy))]
+	default:
+		e = healthy[p.next%uint64(len(healthy))]
+		p.next++
+	}
+
+	atomic.AddInt64(&e.inflight, 1)
+	return e, nil
+}
+
+// done records the outcome of a request sent to the endpoint
+func (p *endpointPool) done(e *endpoint, failed bool) {
+	atomic.AddInt64(&e.inflight, -1)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !failed {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if e.failures >= p.ejectFailures {
+		e.ejectedUntil = time.Now().Add(p.ejectDuration)
+		e.failures = 0
+	}
+}
@@ -0,0 +1,90 @@
+package internalApi
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FormBody(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("Login", "localhost:9999", WithRoute("POST /login"))
+	api.ExpectHook("Login", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "user", r.PostForm.Get("username"))
+		assert.Equal(t, "p&ss", r.PostForm.Get("password"))
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req, err := request.NewRequest(context.Background(), "", nil, request.WithForm(url.Values{"username": {"user"}, "password": {"p&ss"}}))
+	assert.NoError(t, err)
+	_, err = api.Call("Login", req)
+	assert.NoError(t, err)
+}
+
+func Test_BinaryBody(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("Upload", "localhost:9999", WithRoute("PUT /reports/{name}"))
+	api.ExpectHook("Upload", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/pdf", r.Header.Get("Content-Type"))
+		b, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, []byte{0x25, 0x50, 0x44, 0x46}, b)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req, _ := request.NewRequest(context.Background(), "", nil,
+		request.WithPathParams(map[string]string{"name": "q1.pdf"}),
+		request.WithBinary(bytes.NewReader([]byte{0x25, 0x50, 0x44, 0x46}), "application/pdf"))
+	_, err := api.Call("Upload", req)
+	assert.NoError(t, err)
+}
+
+func Test_MultipartBody(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"total": 1}`), 0644))
+
+	api := NewNamed("v1")
+	api.Add("Upload", "localhost:9999", WithRoute("POST /reports"))
+	api.ExpectHook("Upload", func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data; boundary="))
+		assert.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "q1", r.FormValue("title"))
+
+		f, header, err := r.FormFile("report")
+		assert.NoError(t, err)
+		assert.Equal(t, "report.json", header.Filename)
+		assert.Equal(t, "application/json", header.Header.Get("Content-Type"))
+		b, _ := ioutil.ReadAll(f)
+		assert.Equal(t, `{"total": 1}`, string(b))
+
+		f, header, err = r.FormFile("notes")
+		assert.NoError(t, err)
+		assert.Equal(t, "notes.txt", header.Filename)
+		b, _ = ioutil.ReadAll(f)
+		assert.Equal(t, "notes", string(b))
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	req, err := request.NewRequest(context.Background(), "", nil, request.WithMultipart(
+		request.FormField("title", "q1"),
+		request.FilePart("report", path),
+		request.ReaderPart("notes", "notes.txt", "text/plain", strings.NewReader("notes")),
+	))
+	assert.NoError(t, err)
+	_, err = api.Call("Upload", req)
+	assert.NoError(t, err)
+
+	// missing files fail before sending
+	_, err = request.NewRequest(context.Background(), "", nil, request.WithMultipart(request.FilePart("report", filepath.Join(dir, "missing.json"))))
+	assert.Error(t, err)
+}
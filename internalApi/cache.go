@@ -0,0 +1,269 @@
+package internalApi
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheHeader is set on responses served from the cache
+const CacheHeader = "X-From-Cache"
+
+// Cache stores responses to GET requests, see WithCache
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, rsp *CachedResponse)
+	Delete(key string)
+}
+
+// CachedResponse is a stored response with the request headers it varies on
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Vary       map[string]string
+	Stored     time.Time
+}
+
+// lruCache is an in-memory Cache holding up to size responses
+type lruCache struct {
+	size  int
+	lock  sync.Mutex
+	order *list.List               // most recently used first
+	items map[string]*list.Element // key : element{lruItem}
+}
+
+type lruItem struct {
+	key string
+	rsp *CachedResponse
+}
+
+func NewLRUCache(size int) Cache {
+	return &lruCache{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *lruCache) Get(key string) (*CachedResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruItem).rsp, true
+}
+
+func (c *lruCache) Set(key string, rsp *CachedResponse) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		e.Value.(*lruItem).rsp = rsp
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruItem{key: key, rsp: rsp})
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+func (c *lruCache) Delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.order.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(directive, "=")
+		cc[strings.ToLower(key)] = strings.Trim(val, `"`)
+	}
+	return cc
+}
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// how long the response stays fresh, zero requires revalidation
+func (rsp *CachedResponse) freshFor() time.Duration {
+	cc := parseCacheControl(rsp.Header)
+	if cc.has("no-cache") {
+		return 0
+	}
+	if maxAge, err := strconv.Atoi(cc["max-age"]); err == nil {
+		age, _ := strconv.Atoi(rsp.Header.Get("Age"))
+		return time.Duration(maxAge-age) * time.Second
+	}
+	if expires, err := http.ParseTime(rsp.Header.Get("Expires")); err == nil {
+		date, err := http.ParseTime(rsp.Header.Get("Date"))
+		if err != nil {
+			date = rsp.Stored
+		}
+		return expires.Sub(date)
+	}
+	return 0
+}
+
+func (rsp *CachedResponse) fresh() bool {
+	return time.Since(rsp.Stored) < rsp.freshFor()
+}
+
+func (rsp *CachedResponse) matches(req *http.Request) bool {
+	for key, val := range rsp.Vary {
+		if req.Header.Get(key) != val {
+			return false
+		}
+	}
+	return true
+}
+
+func (rsp *CachedResponse) response(req *http.Request) *http.Response {
+	header := rsp.Header.Clone()
+	header.Set(CacheHeader, "1")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rsp.StatusCode, http.StatusText(rsp.StatusCode)),
+		StatusCode:    rsp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(rsp.Body)),
+		ContentLength: int64(len(rsp.Body)),
+		Request:       req,
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func cacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+// withCache serves GET requests from the cache following Cache-Control, ETag and
+// Last-Modified semantics. Other methods invalidate the cached url. The cache is shared by
+// every caller, requests with an Authorization header and private responses bypass it.
+// Bodies over maxBodySize are not cached
+func withCache(cache Cache, maxBodySize int64) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			key := cacheKey(req)
+			if req.Method != http.MethodGet {
+				resp, err := next(req)
+				if err == nil && resp.StatusCode < http.StatusBadRequest {
+					cache.Delete(key)
+				}
+				return resp, err
+			}
+
+			reqCC := parseCacheControl(req.Header)
+			if reqCC.has("no-store") || req.Header.Get("Authorization") != "" {
+				return next(req)
+			}
+
+			cached, ok := cache.Get(key)
+			if ok && !cached.matches(req) {
+				cached, ok = nil, false
+			}
+			if ok && !reqCC.has("no-cache") && cached.fresh() {
+				return cached.response(req), nil
+			}
+
+			// revalidate
+			if ok {
+				if etag := cached.Header.Get("ETag"); etag != "" && req.Header.Get("If-None-Match") == "" {
+					req.Header.Set("If-None-Match", etag)
+				}
+				if modified := cached.Header.Get("Last-Modified"); modified != "" && req.Header.Get("If-Modified-Since") == "" {
+					req.Header.Set("If-Modified-Since", modified)
+				}
+			}
+
+			resp, err := next(req)
+			if err != nil {
+				return resp, err
+			}
+
+			if ok && resp.StatusCode == http.StatusNotModified {
+				_ = resp.Body.Close()
+				updated := *cached
+				updated.Header = cached.Header.Clone()
+				for h, val := range resp.Header {
+					updated.Header[h] = val
+				}
+				updated.Stored = time.Now()
+				cache.Set(key, &updated)
+				return updated.response(req), nil
+			}
+
+			respCC := parseCacheControl(resp.Header)
+			if resp.StatusCode != http.StatusOK || respCC.has("no-store") || respCC.has("private") {
+				return resp, nil
+			}
+
+			// check the response can be cached before reading its body
+			stored := &CachedResponse{
+				StatusCode: resp.StatusCode,
+				Header:     resp.Header.Clone(),
+				Vary:       map[string]string{},
+				Stored:     time.Now(),
+			}
+			for _, vary := range strings.Split(resp.Header.Get("Vary"), ",") {
+				if vary = strings.TrimSpace(vary); vary != "" {
+					stored.Vary[vary] = req.Header.Get(vary)
+				}
+			}
+			if _, varyAll := stored.Vary["*"]; varyAll || (stored.freshFor() <= 0 && stored.Header.Get("ETag") == "" && stored.Header.Get("Last-Modified") == "") {
+				return resp, nil
+			}
+			if maxBodySize > 0 && resp.ContentLength > maxBodySize {
+				return resp, nil
+			}
+
+			var body []byte
+			if maxBodySize > 0 {
+				body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+			} else {
+				body, err = ioutil.ReadAll(resp.Body)
+			}
+			if err != nil {
+				_ = resp.Body.Close()
+				return nil, err
+			}
+			if maxBodySize > 0 && int64(len(body)) > maxBodySize {
+				// too large to cache, the rest is read by the caller
+				resp.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+				return resp, nil
+			}
+			_ = resp.Body.Close()
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			stored.Body = body
+			cache.Set(key, stored)
+			return resp, nil
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package internalApi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Cache(t *testing.T) {
+	var hits, notModified int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		switch r.URL.Path {
+		case "/v1/config":
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte("config"))
+		case "/v1/users":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt64(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte("users"))
+		default:
+			w.Header().Set("Cache-Control", "no-store")
+			_, _ = w.Write([]byte("none"))
+		}
+	}))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	api := NewNamed("v1", WithCache(NewLRUCache(10)))
+	api.Add("Config", host)
+	api.Add("Users", host)
+	api.Add("Other", host)
+
+	get := func(apiName, path string, o ...request.Option) string {
+		req, _ := request.NewRequest(context.Background(), path, nil, o...)
+		b, err := api.Get(apiName, req)
+		assert.NoError(t, err)
+		return string(b)
+	}
+
+	// fresh for max-age
+	assert.Equal(t, "config", get("Config", "/config"))
+	assert.Equal(t, "config", get("Config", "/config"))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&hits))
+
+	// request no-cache revalidates
+	assert.Equal(t, "config", get("Config", "/config", request.WithHeaders(map[string][]string{"Cache-Control": {"no-cache"}})))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&hits))
+
+	// etag is revalidated
+	assert.Equal(t, "users", get("Users", "/users"))
+	assert.Equal(t, "users", get("Users", "/users"))
+	assert.Equal(t, int64(4), atomic.LoadInt64(&hits))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&notModified))
+
+	// not stored
+	assert.Equal(t, "none", get("Other", "/other"))
+	assert.Equal(t, "none", get("Other", "/other"))
+	assert.Equal(t, int64(6), atomic.LoadInt64(&hits))
+
+	// unsafe methods invalidate
+	req, _ := request.NewRequest(context.Background(), "/config", nil)
+	_, err := api.Post("Config", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "config", get("Config", "/config"))
+	assert.Equal(t, int64(8), atomic.LoadInt64(&hits))
+}
+
+func Test_LRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+	cache.Set("a", &CachedResponse{StatusCode: http.StatusOK})
+	cache.Set("b", &CachedResponse{StatusCode: http.StatusOK})
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+
+	cache.Set("c", &CachedResponse{StatusCode: http.StatusOK})
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+
+	cache.Delete("a")
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+}
+
+func Test_CacheBypass(t *testing.T) {
+	var hits int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		switch r.URL.Path {
+		case "/v1/me":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+			_, _ = w.Write([]byte("me"))
+		case "/v1/large":
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+		}
+	}))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	api := NewNamed("v1", WithCache(NewLRUCache(10)))
+	api.Add("Users", host)
+	api.Add("Large", host, WithApiMaxBodySize(50))
+
+	get := func(apiName, path string, o ...request.Option) string {
+		req, _ := request.NewRequest(context.Background(), path, nil, o...)
+		b, err := api.Get(apiName, req)
+		assert.NoError(t, err)
+		return string(b)
+	}
+	count := func(f func()) int64 {
+		before := atomic.LoadInt64(&hits)
+		f()
+		f()
+		return atomic.LoadInt64(&hits) - before
+	}
+
+	// private responses are not cached
+	assert.Equal(t, int64(2), count(func() { assert.Equal(t, "me", get("Users", "/me")) }))
+	// nor responses to requests with credentials, for every caller
+	assert.Equal(t, "Basic YTpi", get("Users", "/users", request.WithAuthBasic("a", "b")))
+	assert.Equal(t, "Basic Yzpk", get("Users", "/users", request.WithAuthBasic("c", "d")))
+	assert.Equal(t, int64(1), count(func() { assert.Equal(t, "", get("Users", "/users")) }))
+
+	// bodies over the max body size are not cached
+	large := func() {
+		req, _ := request.NewRequest(context.Background(), "/large", nil)
+		_, err := api.Get("Large", req)
+		assert.ErrorIs(t, err, ErrBodyTooLarge)
+	}
+	assert.Equal(t, int64(2), count(large))
+}
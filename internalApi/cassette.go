@@ -0,0 +1,324 @@
+package internalApi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+/*
+Cassettes
+- WithRecording records every request/response for an apiName to <dir>/<apiName>.json, see CassettePath.
+  Cassettes are written on NamedApi.Close, bodies over the api's max body size are truncated
+- Replay serves the recorded responses back as the apiName's hook
+*/
+
+// headers that are not written to cassettes
+var unrecordedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+type Cassette struct {
+	ApiName      string        `json:"apiName"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method       string      `json:"method"`
+	Path         string      `json:"path"`
+	Query        string      `json:"query,omitempty"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+	Truncated    bool        `json:"truncated,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode   int         `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+	Truncated    bool        `json:"truncated,omitempty"`
+}
+
+// bodies that are not utf8 are stored base64 encoded
+func encodeBody(b []byte) (string, string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+func decodeBody(body, encoding string) []byte {
+	if encoding == "base64" {
+		b, _ := base64.StdEncoding.DecodeString(body)
+		return b
+	}
+	return []byte(body)
+}
+
+func recordedHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, h := range unrecordedHeaders {
+		header.Del(h)
+	}
+	return header
+}
+
+// separators are escaped so that cassettes stay in their dir, eg. for postman's "folder/name"
+var cassetteName = strings.NewReplacer("%", "%25", "/", "%2F", "\\", "%5C")
+
+// CassettePath is the cassette file for apiName in dir
+func CassettePath(dir, apiName string) string {
+	return filepath.Join(dir, cassetteName.Replace(apiName)+".json")
+}
+
+type recorder struct {
+	dir       string
+	lock      sync.Mutex
+	cassettes map[string]*Cassette // apiName : Cassette
+	modified  map[string]bool      // apiName : not written since the last interaction
+}
+
+func newRecorder(dir string) *recorder {
+	return &recorder{dir: dir, cassettes: map[string]*Cassette{}, modified: map[string]bool{}}
+}
+
+// reads up to maxBodySize bytes of body, maxBodySize 0 reads all of it. The returned body
+// replaces the read one and still yields every byte
+func recordBody(body io.ReadCloser, maxBodySize int64) (recorded []byte, replaced io.ReadCloser, truncated bool, err error) {
+	if maxBodySize > 0 {
+		recorded, err = ioutil.ReadAll(io.LimitReader(body, maxBodySize+1))
+	} else {
+		recorded, err = ioutil.ReadAll(body)
+	}
+	if err != nil {
+		_ = body.Close()
+		return nil, nil, false, err
+	}
+	if maxBodySize > 0 && int64(len(recorded)) > maxBodySize {
+		return recorded[:maxBodySize], &readCloser{Reader: io.MultiReader(bytes.NewReader(recorded), body), Closer: body}, true, nil
+	}
+	_ = body.Close()
+	return recorded, ioutil.NopCloser(bytes.NewReader(recorded)), false, nil
+}
+
+// interceptor recording the exchanges for apiName, bodies over maxBodySize are recorded
+// truncated
+func (rec *recorder) interceptor(apiName string, maxBodySize int64) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			var reqBody []byte
+			var reqTruncated bool
+			if req.Body != nil && req.Body != http.NoBody {
+				var err error
+				if reqBody, req.Body, reqTruncated, err = recordBody(req.Body, maxBodySize); err != nil {
+					return nil, err
+				}
+			}
+
+			resp, err := next(req)
+			if err != nil {
+				return resp, err
+			}
+
+			rspBody, body, rspTruncated, err := recordBody(resp.Body, maxBodySize)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = body
+
+			interaction := Interaction{
+				Request: RecordedRequest{
+					Method:    req.Method,
+					Path:      req.URL.Path,
+					Query:     req.URL.RawQuery,
+					Header:    recordedHeader(req.Header),
+					Truncated: reqTruncated,
+				},
+				Response: RecordedResponse{
+					StatusCode: resp.StatusCode,
+					Header:     recordedHeader(resp.Header),
+					Truncated:  rspTruncated,
+				},
+			}
+			interaction.Request.Body, interaction.Request.BodyEncoding = encodeBody(reqBody)
+			interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(rspBody)
+
+			rec.record(apiName, interaction)
+			return resp, nil
+		}
+	}
+}
+
+// append the interaction to the apiName's cassette, written by flush
+func (rec *recorder) record(apiName string, interaction Interaction) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	cassette := rec.cassettes[apiName]
+	if cassette == nil {
+		cassette = &Cassette{ApiName: apiName}
+		rec.cassettes[apiName] = cassette
+	}
+	cassette.Interactions = append(cassette.Interactions, interaction)
+	rec.modified[apiName] = true
+}
+
+// write the cassettes with interactions recorded since the last flush
+func (rec *recorder) flush() error {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	if len(rec.modified) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(rec.dir, 0755); err != nil {
+		return err
+	}
+	var errs []string
+	for apiName := range rec.modified {
+		b, err := json.MarshalIndent(rec.cassettes[apiName], "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(CassettePath(rec.dir, apiName), b, 0644)
+		}
+		if err != nil {
+			errs = append(errs, apiName+" : "+err.Error())
+			continue
+		}
+		delete(rec.modified, apiName)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Matcher decides if a recorded request matches the request being replayed
+type Matcher func(recorded RecordedRequest, req *http.Request, body []byte) bool
+
+func MatchMethod(recorded RecordedRequest, req *http.Request, _ []byte) bool {
+	return recorded.Method == req.Method
+}
+
+func MatchPath(recorded RecordedRequest, req *http.Request, _ []byte) bool {
+	return recorded.Path == req.URL.Path
+}
+
+func MatchQuery(recorded RecordedRequest, req *http.Request, _ []byte) bool {
+	query, _ := url.ParseQuery(recorded.Query)
+	return query.Encode() == req.URL.Query().Encode()
+}
+
+func MatchBody(recorded RecordedRequest, _ *http.Request, body []byte) bool {
+	if !json.Valid(body) {
+		return bytes.Equal(decodeBody(recorded.Body, recorded.BodyEncoding), body)
+	}
+	return bytes.Equal(normalizeJSON(decodeBody(recorded.Body, recorded.BodyEncoding)), normalizeJSON(body))
+}
+
+// ReadCassette reads a cassette file
+func ReadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(b, &cassette); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s : %v", path, err)
+	}
+	return &cassette, nil
+}
+
+// Replay registers a hook serving the recorded responses for every added apiName with a
+// cassette in dir. Requests are matched by method and path unless matchers are given,
+// recorded responses are served in order and the last match repeats. Requests without a
+// match get a 404. Returns the replayed apiNames
+func (na *NamedApi) Replay(dir string, matchers ...Matcher) ([]string, error) {
+	if len(matchers) == 0 {
+		matchers = []Matcher{MatchMethod, MatchPath}
+	}
+	na.lock.RLock()
+	apiNames := make([]string, 0, len(na.apis))
+	for apiName := range na.apis {
+		apiNames = append(apiNames, apiName)
+	}
+	na.lock.RUnlock()
+
+	var replayed []string
+	for _, apiName := range apiNames {
+		cassette, err := ReadCassette(CassettePath(dir, apiName))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return replayed, err
+		}
+		if err := na.setHook(apiName, replayHook(cassette, matchers)); err != nil {
+			return replayed, err
+		}
+		replayed = append(replayed, apiName)
+	}
+	return replayed, nil
+}
+
+func replayHook(cassette *Cassette, matchers []Matcher) HttpHook {
+	var lock sync.Mutex
+	var used = make([]bool, len(cassette.Interactions))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			body, _ = ioutil.ReadAll(r.Body)
+		}
+
+		lock.Lock()
+		match := -1
+		for i, interaction := range cassette.Interactions {
+			matched := true
+			for _, matcher := range matchers {
+				if !matcher(interaction.Request, r, body) {
+					matched = false
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+			match = i
+			if !used[i] {
+				break
+			}
+		}
+		if match != -1 {
+			used[match] = true
+		}
+		lock.Unlock()
+
+		if match == -1 {
+			http.Error(w, fmt.Sprintf("no recorded interaction for %s %s %s", cassette.ApiName, r.Method, r.URL.Path), http.StatusNotFound)
+			return
+		}
+
+		rsp := cassette.Interactions[match].Response
+		for key, val := range rsp.Header {
+			w.Header()[key] = val
+		}
+		w.WriteHeader(rsp.StatusCode)
+		_, _ = w.Write(decodeBody(rsp.Body, rsp.BodyEncoding))
+	}
+}
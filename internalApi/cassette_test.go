@@ -0,0 +1,149 @@
+package internalApi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	var users []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			users = append(users, r.URL.Query().Get("user"))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"Name":"` + strings.Join(users, ",") + `"}`))
+		}
+	}))
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	// record
+	api := NewNamed("v1", WithRecording(dir))
+	api.Add("GetUser", host)
+	api.Add("AddUser", host)
+
+	for _, name := range []string{"a", "b"} {
+		req, _ := request.NewRequest(context.Background(), "/users", strings.NewReader(`{"Name":"`+name+`"}`),
+			request.WithQueryParams(map[string]string{"user": name}), request.WithAuthBasic("any", "ok"))
+		_, err := api.Post("AddUser", req)
+		assert.NoError(t, err)
+		req, _ = request.NewRequest(context.Background(), "/users", nil)
+		_, err = api.Get("GetUser", req)
+		assert.NoError(t, err)
+	}
+	s.Close()
+
+	// written on Close
+	_, err := ReadCassette(CassettePath(dir, "AddUser"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.NoError(t, api.Close())
+
+	cassette, err := ReadCassette(CassettePath(dir, "AddUser"))
+	assert.NoError(t, err)
+	assert.Len(t, cassette.Interactions, 2)
+	assert.Empty(t, cassette.Interactions[0].Request.Header.Get("Authorization"))
+
+	// replay offline
+	replay := NewNamed("v1")
+	replay.Add("GetUser", host)
+	replay.Add("AddUser", host)
+	replay.Add("Other", host)
+	replayed, err := replay.Replay(dir)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"GetUser", "AddUser"}, replayed)
+
+	get := func() string {
+		req, _ := request.NewRequest(context.Background(), "/users", nil)
+		b, err := replay.Get("GetUser", req)
+		assert.NoError(t, err)
+		return string(b)
+	}
+	assert.Equal(t, `{"Name":"a"}`, get())
+	assert.Equal(t, `{"Name":"a,b"}`, get())
+	assert.Equal(t, `{"Name":"a,b"}`, get())
+
+	// match on query and body
+	replay = NewNamed("v1")
+	replay.Add("AddUser", host)
+	_, err = replay.Replay(dir, MatchMethod, MatchPath, MatchQuery, MatchBody)
+	assert.NoError(t, err)
+
+	req, _ := request.NewRequest(context.Background(), "/users", strings.NewReader(`{ "Name": "b" }`), request.WithQueryParams(map[string]string{"user": "b"}))
+	_, err = replay.Post("AddUser", req)
+	assert.NoError(t, err)
+
+	req, _ = request.NewRequest(context.Background(), "/users", strings.NewReader(`{"Name":"c"}`), request.WithQueryParams(map[string]string{"user": "c"}))
+	_, err = replay.Post("AddUser", req)
+	assert.Error(t, err)
+}
+
+func Test_RecordingErrors(t *testing.T) {
+	dir := t.TempDir()
+	// cassettes stay in dir
+	assert.Equal(t, filepath.Join(dir, "users%2FGet.json"), CassettePath(dir, "users/Get"))
+	assert.Equal(t, filepath.Join(dir, "..%2F..%2Fetc.json"), CassettePath(dir, "../../etc"))
+
+	// failing to record doesn't fail the call, writing the cassette fails Close
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, ioutil.WriteFile(file, nil, 0644))
+	api := NewNamed("v1", WithRecording(file))
+	api.Add("users/Get", "localhost:9999")
+	api.ExpectHook("users/Get", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("user"))
+	})
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	b, err := api.Get("users/Get", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", string(b))
+	assert.Error(t, api.Close())
+
+	// replaying over a hook is an error
+	api = NewNamed("v1", WithRecording(dir))
+	api.Add("users/Get", "localhost:9999")
+	api.ExpectHook("users/Get", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	_, err = api.Get("users/Get", req)
+	assert.NoError(t, err)
+	assert.NoError(t, api.Close())
+	_, err = api.Replay(dir)
+	assert.Error(t, err)
+}
+
+func Test_RecordingMaxBodySize(t *testing.T) {
+	dir := t.TempDir()
+	api := NewNamed("v1", WithRecording(dir), WithMaxBodySize(8))
+	api.Add("Echo", "localhost:9999")
+	api.ExpectHook("Echo", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(b[:6])
+	})
+
+	// the whole request is sent, the recorded one is truncated
+	req, _ := request.NewRequest(context.Background(), "/echo", strings.NewReader("0123456789"))
+	b, err := api.Post("Echo", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "012345", string(b))
+	assert.NoError(t, api.Close())
+
+	cassette, err := ReadCassette(CassettePath(dir, "Echo"))
+	assert.NoError(t, err)
+	assert.Len(t, cassette.Interactions, 1)
+	assert.Equal(t, "01234567", cassette.Interactions[0].Request.Body)
+	assert.True(t, cassette.Interactions[0].Request.Truncated)
+	assert.Equal(t, "012345", cassette.Interactions[0].Response.Body)
+	assert.False(t, cassette.Interactions[0].Response.Truncated)
+}
@@ -2,84 +2,177 @@ package internalApi
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 
 	"github.com/Ishan27g/go-utils/tracing"
-	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 )
 
 type HttpHook func(w http.ResponseWriter, r *http.Request)
 
+// StatusError is returned for responses with a status code above 202
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Bad response status : %s", e.Status)
+}
+
+type errorBodyKey struct{}
+
+// withErrorBody keeps the body of unsuccessful responses for the caller, which must close it
+// and check the status, eg. to decode GraphQL errors
+func withErrorBody(ctx context.Context) context.Context {
+	return context.WithValue(ctx, errorBodyKey{}, true)
+}
+
+// connection errors and server errors count against an endpoint's health
+func isEndpointFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
 type apiClient struct {
 	httpClient http.Client
+	scheme     string
+	basePath   *string // overrides NamedApi's base path
+	limiter    *tokenBucket
+	tls        *tls.Config
+	// the transport was cloned for tls, else it is shared with the NamedApi
+	ownsTransport bool
 
 	hooks           map[string]*HttpHook // apiName:HttpHook
 	tracingProvider tracing.TraceProvider
+	logger          Logger
+
+	grpcHooks map[string]*GrpcHook // apiName:GrpcHook
+	grpcLock  sync.Mutex
+	grpcConns map[string]*grpc.ClientConn // target:conn
 }
 
-func (c *apiClient) do(ctx context.Context, hook *HttpHook, req *http.Request) (rsp []byte, err error) {
+// snapshot of the client's settings for a request, without hooks or grpc connections.
+// Called with the NamedApi's lock held
+func (c *apiClient) snapshot() *apiClient {
+	return &apiClient{
+		httpClient:      c.httpClient,
+		scheme:          c.scheme,
+		basePath:        c.basePath,
+		limiter:         c.limiter,
+		tls:             c.tls,
+		tracingProvider: c.tracingProvider,
+		logger:          c.logger,
+	}
+}
+
+// ErrBodyTooLarge is returned when a response body exceeds the configured max body size
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// sends the request via the interceptors to the hook or the host. The response body is
+// left unread for successful responses, and for any response if the context is withErrorBody
+func (c *apiClient) do(ctx context.Context, hook *HttpHook, req *http.Request, interceptors ...Interceptor) (resp *http.Response, err error) {
 
-	var resp *http.Response
 	var hooked = false
 
 	span := trace.SpanFromContext(ctx)
 
-	span.SetAttributes(semconv.HTTPRouteKey.String(req.URL.Path))
+	route, ok := ctx.Value(routeKey{}).(string)
+	if !ok {
+		route = req.URL.Path
+	}
+	span.SetAttributes(semconv.HTTPRouteKey.String(route))
 	span.SetAttributes(semconv.HTTPMethodKey.String(req.Method))
 
-	var logFields = func() log.Fields {
-		return log.Fields{"url": req.URL.String(), "method": req.Method}
+	logger := withTrace(ctx, c.logger)
+	var logFields = func() []interface{} {
+		return []interface{}{"url", req.URL.String(), "method", req.Method}
 	}
 
-	// if hooks is set
-	if hook != nil {
-		hooked = true
-		rr := httptest.NewRecorder()
-		rr.Code = -1
-		(*hook)(rr, req)
-		if rr.Code != -1 {
-			resp = rr.Result()
+	send := func(req *http.Request) (*http.Response, error) {
+		// if hooks is set
+		if hook != nil {
+			hooked = true
+			rr := httptest.NewRecorder()
+			rr.Code = -1
+			(*hook)(rr, req)
+			logger.Debug("hooked", logFields()...)
+			if rr.Code != -1 {
+				return rr.Result(), nil
+			}
 		}
-		log.WithFields(logFields()).Debug("hooked")
+		// if no hooks is set, do actual http call
+		return c.httpClient.Do(req)
 	}
 
-	// if no hooks is set, do actual http call
-	if resp == nil {
-		resp, err = c.httpClient.Do(req)
-	}
+	resp, err = chain(send, interceptors...)(req)
 
 	if err != nil {
 		span.SetStatus(codes.Error, fmt.Sprintf("error sending request : %v", err.Error()))
-		log.WithFields(logFields()).Error(fmt.Sprintf("error sending request : %v", err.Error()))
+		logger.Error("error sending request", append(logFields(), "error", err)...)
 		return nil, err
 	}
 
-	if resp.StatusCode > http.StatusAccepted {
-		log.WithFields(logFields()).Debug(fmt.Sprintf("Bad response status : %s", resp.Status))
-		return nil, errors.New(fmt.Sprintf("Bad response status : %s", resp.Status))
+	span.SetAttributes(attribute.Key("api-hooked?").Bool(hooked))
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	span.SetAttributes(semconv.HTTPResponseContentLengthKey.Int64(resp.ContentLength))
+
+	if resp.StatusCode > http.StatusAccepted && ctx.Value(errorBodyKey{}) == nil {
+		_ = resp.Body.Close()
+		logger.Debug("bad response status", append(logFields(), "status", resp.Status)...)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	if resp.Request == nil {
+		resp.Request = req
+	}
+	return resp, nil
+
+}
+
+// reads and closes the response body, up to maxBodySize bytes if set
+func readBody(logger Logger, resp *http.Response, maxBodySize int64) (rsp []byte, err error) {
+
+	defer resp.Body.Close()
+
+	span := trace.SpanFromContext(resp.Request.Context())
+	var logFields = func() []interface{} {
+		return []interface{}{"url", resp.Request.URL.String(), "method", resp.Request.Method}
 	}
 
-	rsp, err = ioutil.ReadAll(resp.Body)
+	if maxBodySize > 0 {
+		rsp, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+		if err == nil && int64(len(rsp)) > maxBodySize {
+			rsp, err = nil, fmt.Errorf("%w : over %d bytes", ErrBodyTooLarge, maxBodySize)
+		}
+	} else {
+		rsp, err = ioutil.ReadAll(resp.Body)
+	}
 
 	if err != nil {
 		span.SetStatus(codes.Error, fmt.Sprintf("error reading response [%s] : %v", resp.Status, err.Error()))
-		log.WithFields(logFields()).Error(fmt.Sprintf("error reading response : %v", err.Error()))
+		logger.Error("error reading response", append(logFields(), "status", resp.Status, "error", err)...)
 		return nil, err
 	}
 
-	span.SetAttributes(attribute.Key("api-hooked?").Bool(hooked))
-	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
-	span.SetAttributes(semconv.HTTPResponseContentLengthKey.Int64(resp.ContentLength))
-
-	log.WithFields(logFields()).Debug(fmt.Sprintf("response [%s] - %s", resp.Status, string(rsp)))
+	logger.Debug("response", append(logFields(), "status", resp.Status, "body", string(rsp))...)
 
 	return
 
@@ -0,0 +1,238 @@
+package internalApi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/*
+Compression
+- request bodies over a size threshold are compressed with gzip or zstd, see WithCompression.
+  Bodies of unknown length, eg. request.WithMultipart, are compressed as they are sent
+- responses are accepted as gzip or zstd and decompressed before they are read, max body
+  sizes apply to the decompressed body
+*/
+
+// Encoding of request and response bodies
+type Encoding string
+
+const (
+	Gzip Encoding = "gzip"
+	Zstd Encoding = "zstd"
+)
+
+// acceptEncoding advertised to hosts
+const acceptEncoding = "zstd, gzip"
+
+// Compression compresses request bodies of at least MinSize bytes with Encoding, gzip by
+// default, and accepts compressed responses
+type Compression struct {
+	Encoding Encoding `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+	MinSize  int64    `json:"minSize,omitempty" yaml:"minSize,omitempty"`
+}
+
+// WithCompression compresses requests and accepts compressed responses for this apiName
+func WithCompression(compression Compression) ApiOption {
+	return func(config *apiConfig) {
+		if compression.Encoding == "" {
+			compression.Encoding = Gzip
+		}
+		switch compression.Encoding {
+		case Gzip, Zstd:
+		default:
+			config.err = fmt.Errorf("unsupported compression %s", compression.Encoding)
+		}
+		config.compression = &compression
+	}
+}
+
+// safe for concurrent use with EncodeAll
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+func compress(encoding Encoding, body []byte) ([]byte, error) {
+	if encoding == Zstd {
+		return zstdEncoder.EncodeAll(body, make([]byte, 0, len(body)/2)), nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c Compression) interceptor(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		// compress the body unless it is small or already encoded
+		hasBody := req.Body != nil && req.Body != http.NoBody
+		if hasBody && req.Header.Get("Content-Encoding") == "" && (req.ContentLength <= 0 || req.ContentLength >= c.MinSize) {
+			if err := c.compressRequest(req); err != nil {
+				return nil, err
+			}
+		}
+
+		// responses are decompressed only if we asked for them, else it's up to the caller
+		accepted := req.Header.Get("Accept-Encoding") == ""
+		if accepted {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := next(req)
+		if err != nil || !accepted {
+			return resp, err
+		}
+		decompress(req.Method, resp)
+		return resp, nil
+	}
+}
+
+// compress the request body. Replayable bodies are compressed in memory and stay
+// replayable, others are compressed as they are sent
+func (c Compression) compressRequest(req *http.Request) error {
+	if req.GetBody != nil {
+		body, err := replayableBody(req)
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) < c.MinSize {
+			return nil
+		}
+		compressed, err := compress(c.Encoding, body)
+		if err != nil {
+			return fmt.Errorf("error compressing request : %v", err)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+		}
+		req.ContentLength = int64(len(compressed))
+		req.Header.Set("Content-Encoding", string(c.Encoding))
+		return nil
+	}
+
+	body := req.Body
+	if req.ContentLength <= 0 && c.MinSize > 0 {
+		// unknown length, peek at up to MinSize bytes
+		peeked := make([]byte, c.MinSize)
+		n, err := io.ReadFull(body, peeked)
+		switch err {
+		case nil:
+			body = &readCloser{Reader: io.MultiReader(bytes.NewReader(peeked), body), Closer: body}
+		case io.EOF, io.ErrUnexpectedEOF:
+			_ = body.Close()
+			req.Body = ioutil.NopCloser(bytes.NewReader(peeked[:n]))
+			req.ContentLength = int64(n)
+			return nil
+		default:
+			_ = body.Close()
+			return err
+		}
+	}
+	req.Body = &compressedBody{encoding: c.Encoding, body: body}
+	req.GetBody = nil
+	req.ContentLength = -1
+	req.Header.Set("Content-Encoding", string(c.Encoding))
+	return nil
+}
+
+// compressedBody compresses body through a pipe once it is first read
+type compressedBody struct {
+	encoding Encoding
+	body     io.ReadCloser
+	once     sync.Once
+	pipe     *io.PipeReader
+}
+
+func (b *compressedBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		r, w := io.Pipe()
+		b.pipe = r
+		go func() {
+			var encoder io.WriteCloser
+			if b.encoding == Zstd {
+				encoder, _ = zstd.NewWriter(w)
+			} else {
+				encoder = gzip.NewWriter(w)
+			}
+			_, err := io.Copy(encoder, b.body)
+			if closeErr := encoder.Close(); err == nil {
+				err = closeErr
+			}
+			_ = w.CloseWithError(err)
+		}()
+	})
+	return b.pipe.Read(p)
+}
+
+// Close stops the compression, the writer fails on the closed pipe
+func (b *compressedBody) Close() error {
+	b.once.Do(func() {})
+	if b.pipe != nil {
+		_ = b.pipe.Close()
+	}
+	return b.body.Close()
+}
+
+// decompress the response body as it is read. Responses without a body are left as is
+func decompress(method string, resp *http.Response) {
+	encoding := Encoding(strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))))
+	if encoding != Gzip && encoding != Zstd {
+		return
+	}
+	noBody := resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified ||
+		resp.ContentLength == 0 || method == http.MethodHead
+	if noBody {
+		return
+	}
+	resp.Body = &decompressedBody{encoding: encoding, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decompressedBody creates the decoder on the first read, as decoders read the header
+type decompressedBody struct {
+	encoding Encoding
+	body     io.ReadCloser
+	decoder  io.Reader
+	err      error
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.decoder == nil && b.err == nil {
+		switch b.encoding {
+		case Gzip:
+			b.decoder, b.err = gzip.NewReader(b.body)
+		case Zstd:
+			var d *zstd.Decoder
+			if d, b.err = zstd.NewReader(b.body, zstd.WithDecoderConcurrency(1)); b.err == nil {
+				b.decoder = d.IOReadCloser()
+			}
+		}
+		if b.err != nil {
+			b.err = fmt.Errorf("error decompressing response : %v", b.err)
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.decoder.Read(p)
+}
+
+func (b *decompressedBody) Close() error {
+	if c, ok := b.decoder.(io.Closer); ok {
+		_ = c.Close()
+	}
+	return b.body.Close()
+}
@@ -0,0 +1,158 @@
+package internalApi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Compression(t *testing.T) {
+	users := `[` + strings.Repeat(`{"name":"user"},`, 100) + `{"name":"user"}]`
+	var encodings []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		var body []byte
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			reader, err := gzip.NewReader(r.Body)
+			assert.NoError(t, err)
+			body, _ = ioutil.ReadAll(reader)
+		case "zstd":
+			decoder, err := zstd.NewReader(r.Body)
+			assert.NoError(t, err)
+			body, _ = ioutil.ReadAll(decoder)
+			decoder.Close()
+		default:
+			body, _ = ioutil.ReadAll(r.Body)
+		}
+
+		// respond with the preferred encoding
+		if strings.HasPrefix(r.Header.Get("Accept-Encoding"), "zstd") {
+			w.Header().Set("Content-Encoding", "zstd")
+			encoder, _ := zstd.NewWriter(w)
+			_, _ = encoder.Write(body)
+			_ = encoder.Close()
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	api := NewNamed("v1")
+	api.Add("AddUsers", host, WithRoute("POST /users"), WithCompression(Compression{MinSize: 1024}))
+	api.Add("AddUsersZstd", host, WithRoute("POST /users"), WithCompression(Compression{Encoding: Zstd, MinSize: 1024}))
+	api.Add("Uncompressed", host, WithRoute("POST /users"))
+
+	call := func(apiName, body string) string {
+		req, _ := request.NewRequest(context.Background(), "", strings.NewReader(body))
+		rsp, err := api.Call(apiName, req)
+		assert.NoError(t, err)
+		return string(rsp)
+	}
+	assert.Equal(t, users, call("AddUsers", users))
+	assert.Equal(t, users, call("AddUsersZstd", users))
+	// under the threshold
+	assert.Equal(t, `{"name":"user"}`, call("AddUsers", `{"name":"user"}`))
+	// go's transport negotiates gzip by itself, responses are not zstd
+	assert.Equal(t, users, call("Uncompressed", users))
+	assert.Equal(t, []string{"gzip", "zstd", "", ""}, encodings)
+
+	api.Add("Brotli", host, WithCompression(Compression{Encoding: "br"}))
+	req, _ := request.NewRequest(context.Background(), "", nil)
+	_, err := api.Call("Brotli", req)
+	assert.Error(t, err)
+}
+
+func Test_CompressionMaxBodySize(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("GetUsers", "localhost:9999", WithCompression(Compression{}), WithApiMaxBodySize(1024))
+	api.ExpectHook("GetUsers", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "zstd, gzip", r.Header.Get("Accept-Encoding"))
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, _ = writer.Write(bytes.Repeat([]byte("a"), 4096))
+		_ = writer.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+	})
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Call("GetUsers", req)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+}
+
+func Test_CompressionNoBody(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", "100")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	api := NewNamed("v1")
+	api.Add("HeadUser", host, WithRoute("HEAD /users"), WithCompression(Compression{}))
+	api.Add("DeleteUser", host, WithRoute("DELETE /users"), WithCompression(Compression{}))
+	req, _ := request.NewRequest(context.Background(), "", nil)
+	_, err := api.Call("HeadUser", req)
+	assert.NoError(t, err)
+
+	// the status is reported rather than a decompression error
+	resp, err := api.Stream("DeleteUser", http.MethodDelete, req)
+	var statusErr *StatusError
+	if assert.True(t, errors.As(err, &statusErr), err) {
+		assert.Equal(t, http.StatusNoContent, statusErr.StatusCode)
+	}
+	assert.Nil(t, resp)
+}
+
+func Test_CompressionStreamed(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("Upload", "localhost:9999", WithRoute("POST /files"), WithCompression(Compression{Encoding: Zstd, MinSize: 1024}))
+	var body []byte
+	api.ExpectHook("Upload", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "zstd", r.Header.Get("Content-Encoding"))
+		decoder, err := zstd.NewReader(r.Body)
+		assert.NoError(t, err)
+		body, _ = ioutil.ReadAll(decoder)
+		decoder.Close()
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// a multipart body is piped, its length is unknown
+	content := strings.Repeat("a", 4096)
+	req, err := request.NewRequest(context.Background(), "", nil,
+		request.WithMultipart(request.ReaderPart("file", "a.txt", "text/plain", strings.NewReader(content))))
+	assert.NoError(t, err)
+	_, err = api.Call("Upload", req)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), content)
+
+	// under the threshold
+	api.RemoveHook("Upload")
+	api.ExpectHook("Upload", func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Content-Encoding"))
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+	req, _ = request.NewRequest(context.Background(), "", nil,
+		request.WithMultipart(request.FormField("name", "a")))
+	_, err = api.Call("Upload", req)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `name="name"`)
+}
@@ -0,0 +1,389 @@
+package internalApi
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+Config
+- the version, hosts and named apis of a NamedApi in yaml or json
+- ${VAR} and ${VAR:-default} in values are replaced by environment variables, $${ by ${.
+  Other $ are kept as is
+- WatchConfig reloads the file when it changes, replacing the hosts and named apis
+*/
+
+// Config of a NamedApi, see LoadConfig
+type Config struct {
+	Version     string               `json:"version" yaml:"version"`
+	BasePath    string               `json:"basePath,omitempty" yaml:"basePath,omitempty"`
+	Headers     map[string]string    `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Timeout     time.Duration        `json:"timeout,omitempty" yaml:"timeout,omitempty"` // for apis without a timeout
+	MaxBodySize int64                `json:"maxBodySize,omitempty" yaml:"maxBodySize,omitempty"`
+	Hosts       map[string]HostEntry `json:"hosts" yaml:"hosts"` // name : host
+	Apis        map[string]ApiEntry  `json:"apis" yaml:"apis"`   // apiName : api
+}
+
+// HostEntry configures a host, see AddHost
+type HostEntry struct {
+	Address    string     `json:"address" yaml:"address"` // host:port or scheme://host:port
+	BasePath   *string    `json:"basePath,omitempty" yaml:"basePath,omitempty"`
+	CAFile     string     `json:"caFile,omitempty" yaml:"caFile,omitempty"`
+	CertFile   string     `json:"certFile,omitempty" yaml:"certFile,omitempty"`
+	KeyFile    string     `json:"keyFile,omitempty" yaml:"keyFile,omitempty"`
+	ServerName string     `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+	RateLimit  *RateLimit `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty"`
+}
+
+// ApiEntry configures a named api, see Add
+type ApiEntry struct {
+	Host        string            `json:"host" yaml:"host"`                       // name of a host, or an address
+	Route       string            `json:"route,omitempty" yaml:"route,omitempty"` // eg. GET /users/{id}
+	GrpcMethod  string            `json:"grpcMethod,omitempty" yaml:"grpcMethod,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Timeout     time.Duration     `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	MaxBodySize int64             `json:"maxBodySize,omitempty" yaml:"maxBodySize,omitempty"`
+	Retry       *Retry            `json:"retry,omitempty" yaml:"retry,omitempty"`
+	RateLimit   *RateLimit        `json:"rateLimit,omitempty" yaml:"rateLimit,omitempty"`
+	Compression *Compression      `json:"compression,omitempty" yaml:"compression,omitempty"`
+}
+
+// ParseConfig reads a yaml or json config, replacing environment variables
+func ParseConfig(r io.Reader) (*Config, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil { // yaml is a superset of json
+		return nil, fmt.Errorf("invalid config : %v", err)
+	}
+	var missing []string
+	expandNode(&doc, &missing)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("invalid config : environment variables not set %s", strings.Join(missing, ", "))
+	}
+
+	var config Config
+	if err := doc.Decode(&config); err != nil {
+		return nil, fmt.Errorf("invalid config : %v", err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config : %v", err)
+	}
+	return &config, nil
+}
+
+var envVariable = regexp.MustCompile(`\$\$\{|\$\{([^{}]*)\}`)
+
+// expand ${VAR} and ${VAR:-default} in the values of the node, keys are left as is
+func expandNode(node *yaml.Node, missing *[]string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, n := range node.Content {
+			expandNode(n, missing)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			expandNode(node.Content[i], missing)
+		}
+	case yaml.ScalarNode:
+		if !envVariable.MatchString(node.Value) {
+			return
+		}
+		node.Value = envVariable.ReplaceAllStringFunc(node.Value, func(m string) string {
+			if m == "$${" {
+				return "${"
+			}
+			name, fallback, hasFallback := strings.Cut(m[2:len(m)-1], ":-")
+			val, ok := os.LookupEnv(name)
+			if !ok || (hasFallback && val == "") {
+				if !hasFallback {
+					*missing = append(*missing, name)
+				}
+				return fallback
+			}
+			return val
+		})
+		// unquoted values are resolved again, eg. as numbers
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	}
+}
+
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseConfig(f)
+}
+
+func (c *Config) validate() error {
+	for name, h := range c.Hosts {
+		if h.Address == "" {
+			return fmt.Errorf("host %s without an address", name)
+		}
+	}
+	for apiName, api := range c.Apis {
+		if api.Host == "" {
+			return fmt.Errorf("api %s without a host", apiName)
+		}
+		if api.Route != "" && api.GrpcMethod != "" {
+			return fmt.Errorf("api %s with both a route and a grpc method", apiName)
+		}
+		if api.Route != "" {
+			if _, err := parseRoute(routePath(api.Route)); err != nil {
+				return fmt.Errorf("api %s : %v", apiName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// path of a route, eg. GET /users/{id}
+func routePath(route string) string {
+	if _, path, ok := strings.Cut(strings.TrimSpace(route), " "); ok {
+		return strings.TrimSpace(path)
+	}
+	return route
+}
+
+func (h HostEntry) options() []HostOption {
+	var options []HostOption
+	if h.BasePath != nil {
+		options = append(options, WithHostBasePath(*h.BasePath))
+	}
+	if h.CAFile != "" {
+		options = append(options, WithCAFile(h.CAFile))
+	}
+	if h.CertFile != "" || h.KeyFile != "" {
+		options = append(options, WithClientCert(h.CertFile, h.KeyFile))
+	}
+	if h.ServerName != "" {
+		options = append(options, WithServerName(h.ServerName))
+	}
+	if h.RateLimit != nil {
+		options = append(options, WithHostRateLimit(*h.RateLimit))
+	}
+	return options
+}
+
+func (a ApiEntry) options(c *Config) []ApiOption {
+	options := []ApiOption{WithApiHeaders(a.Headers)}
+	if a.Route != "" {
+		options = append(options, WithRoute(a.Route))
+	}
+	if a.GrpcMethod != "" {
+		options = append(options, WithGrpcMethod(a.GrpcMethod))
+	}
+	timeout := a.Timeout
+	if timeout == 0 {
+		timeout = c.Timeout
+	}
+	if timeout > 0 {
+		options = append(options, WithTimeout(timeout))
+	}
+	if a.MaxBodySize > 0 {
+		options = append(options, WithApiMaxBodySize(a.MaxBodySize))
+	}
+	if a.Retry != nil {
+		options = append(options, WithRetry(*a.Retry))
+	}
+	if a.RateLimit != nil {
+		options = append(options, WithRateLimit(*a.RateLimit))
+	}
+	if a.Compression != nil {
+		options = append(options, WithCompression(*a.Compression))
+	}
+	return options
+}
+
+// NewNamedFromConfig returns a NamedApi with the config's version, hosts and named apis
+func NewNamedFromConfig(config *Config, options ...Option) (*NamedApi, error) {
+	na := NewNamed(config.Version, options...)
+	if err := na.ApplyConfig(config); err != nil {
+		return nil, err
+	}
+	return na, nil
+}
+
+// ApplyConfig merges the config into the NamedApi: the version, default headers, hosts and
+// named apis are replaced by the config's, named apis of the previous config missing from
+// this one are removed. Named apis and hosts added in code are kept, as are the hooks of
+// named apis. Named apis whose entry is unchanged keep their rate limits and endpoint health.
+// Requests in flight complete with the previous config
+func (na *NamedApi) ApplyConfig(config *Config) error {
+	// build the hosts and named apis aside, then merge them in
+	next := &NamedApi{
+		httpClient:       na.httpClient,
+		tracingProvider:  na.tracingProvider,
+		tracingTransport: na.tracingTransport,
+		logger:           na.logger,
+		hosts:            host{},
+		apiClient:        map[string]*apiClient{},
+		apis:             map[string]*apiConfig{},
+	}
+
+	addresses := map[string]string{} // host name : host
+	for name, h := range config.Hosts {
+		if err := next.AddHost(h.Address, h.options()...); err != nil {
+			return fmt.Errorf("invalid config for host %s : %v", name, err)
+		}
+		addresses[name] = h.Address
+	}
+	apis := map[string]*apiConfig{}
+	for apiName, a := range config.Apis {
+		address, ok := addresses[a.Host]
+		if !ok {
+			address = a.Host
+		}
+		addresses[apiName] = address
+		apis[apiName] = newApiConfig(apiName, a.options(config)...)
+		if err := apis[apiName].err; err != nil {
+			return fmt.Errorf("invalid config for api %s : %v", apiName, err)
+		}
+	}
+
+	headers := map[string]string{}
+	for key, val := range config.Headers {
+		headers[key] = val
+	}
+
+	na.lock.Lock()
+	defer na.lock.Unlock()
+
+	// named apis removed from the config
+	if na.config != nil {
+		for apiName := range na.config.Apis {
+			if _, ok := config.Apis[apiName]; !ok {
+				na.unlink(apiName)
+				delete(na.apis, apiName)
+			}
+		}
+	}
+
+	// hosts whose settings changed are replaced, keeping their hooks
+	var replaced []*apiClient
+	for h, client := range na.apiClient {
+		if reflect.DeepEqual(config.hostEntry(h), na.config.hostEntry(h)) {
+			continue
+		}
+		nextClient := next.apiClient[h]
+		if nextClient == nil {
+			nextClient = next.client(h)
+		}
+		nextClient.hooks, nextClient.grpcHooks = client.hooks, client.grpcHooks
+		na.apiClient[h] = nextClient
+		replaced = append(replaced, client)
+	}
+	for h, nextClient := range next.apiClient {
+		if na.apiClient[h] == nil {
+			na.apiClient[h] = nextClient
+		}
+	}
+
+	for apiName, apiConfig := range apis {
+		if na.unchanged(config, apiName) {
+			// rate limits and endpoint health are kept
+			apiConfig = na.apis[apiName]
+		}
+		na.add(apiName, addresses[apiName], apiConfig)
+	}
+
+	na.version, na.basePath, na.config = config.Version, basePath(config.Version), config
+	if config.BasePath != "" {
+		na.basePath = basePath(config.BasePath)
+	}
+	na.defaultHeaders = headers
+	if config.MaxBodySize > 0 {
+		na.maxBodySize = config.MaxBodySize
+	}
+
+	for _, client := range replaced {
+		// other clients share the NamedApi's transport
+		if client.ownsTransport {
+			client.httpClient.CloseIdleConnections()
+		}
+		_ = client.closeGrpc()
+	}
+	return nil
+}
+
+// the apiName was applied from an identical entry, called with the lock held
+func (na *NamedApi) unchanged(config *Config, apiName string) bool {
+	if na.config == nil || na.apis[apiName] == nil || na.config.Timeout != config.Timeout {
+		return false
+	}
+	previous, ok := na.config.Apis[apiName]
+	return ok && reflect.DeepEqual(previous, config.Apis[apiName])
+}
+
+// entry of the host in the config, if any
+func (c *Config) hostEntry(h string) *HostEntry {
+	if c == nil {
+		return nil
+	}
+	for _, entry := range c.Hosts {
+		if _, address := splitScheme(entry.Address); address == h {
+			return &entry
+		}
+	}
+	return nil
+}
+
+// ConfigWatcher reloads a config file when it changes, see WatchConfig
+type ConfigWatcher struct {
+	na      *NamedApi
+	path    string
+	watcher *fileWatcher
+}
+
+// WatchConfig applies the config file and polls it for changes every interval until the
+// watcher is closed. An invalid config is logged and reported by Err, the previous config
+// stays in place
+func (na *NamedApi) WatchConfig(path string, interval time.Duration) (*ConfigWatcher, error) {
+	w := &ConfigWatcher{na: na, path: path}
+	w.watcher = newFileWatcher(path, w.load)
+	if err := w.watcher.reload(); err != nil {
+		return nil, err
+	}
+	go w.watcher.watch(interval, func(err error) {
+		na.logger.Error("error reloading config", "path", path, "error", err)
+	})
+	return w, nil
+}
+
+// Err returns the error of the last reload, if it failed
+func (w *ConfigWatcher) Err() error {
+	return w.watcher.lastErr()
+}
+
+// Close stops watching the file
+func (w *ConfigWatcher) Close() {
+	w.watcher.close()
+}
+
+// apply the modified file
+func (w *ConfigWatcher) load() error {
+	config, err := LoadConfig(w.path)
+	if err != nil {
+		return err
+	}
+	if err := w.na.ApplyConfig(config); err != nil {
+		return err
+	}
+	w.na.logger.Debug("applied config", "path", w.path)
+	return nil
+}
@@ -0,0 +1,227 @@
+package internalApi
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `
+version: v1
+headers:
+  X-Env: ${TEST_ENV:-dev}
+timeout: 2s
+hosts:
+  users:
+    address: ${TEST_USERS_HOST}
+    rateLimit:
+      rate: ${TEST_RATE:-100} # $TEST_RATE
+      burst: 10
+apis:
+  GetUser:
+    host: users
+    route: GET /users/{id}
+    timeout: 500ms
+    retry:
+      attempts: 3
+      backoff: 1ms
+  AddUser:
+    host: users
+    route: POST /users
+    headers:
+      X-Price: $1
+      X-Password: pa$$word$HOME
+      X-Template: "$${NAME}"
+    compression:
+      encoding: zstd
+      minSize: 1024
+  Health:
+    host: localhost:50051
+    grpcMethod: /grpc.health.v1.Health/Check
+`
+
+func Test_ParseConfig(t *testing.T) {
+	t.Setenv("TEST_USERS_HOST", "https://users:9443")
+
+	config, err := ParseConfig(strings.NewReader(testConfig))
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", config.Version)
+	assert.Equal(t, "dev", config.Headers["X-Env"])
+	assert.Equal(t, 2*time.Second, config.Timeout)
+	assert.Equal(t, "https://users:9443", config.Hosts["users"].Address)
+	assert.Equal(t, 100.0, config.Hosts["users"].RateLimit.Rate)
+	assert.Equal(t, 500*time.Millisecond, config.Apis["GetUser"].Timeout)
+	assert.Equal(t, Retry{Attempts: 3, Backoff: time.Millisecond}, *config.Apis["GetUser"].Retry)
+	assert.Equal(t, "$1", config.Apis["AddUser"].Headers["X-Price"])
+	assert.Equal(t, "pa$$word$HOME", config.Apis["AddUser"].Headers["X-Password"])
+	assert.Equal(t, "${NAME}", config.Apis["AddUser"].Headers["X-Template"])
+	assert.Equal(t, Compression{Encoding: Zstd, MinSize: 1024}, *config.Apis["AddUser"].Compression)
+
+	// json
+	config, err = ParseConfig(strings.NewReader(`{"version": "v2", "apis": {"GetUser": {"host": "${TEST_USERS_HOST}", "timeout": "1s"}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://users:9443", config.Apis["GetUser"].Host)
+	assert.Equal(t, time.Second, config.Apis["GetUser"].Timeout)
+
+	os.Unsetenv("TEST_USERS_HOST")
+	_, err = ParseConfig(strings.NewReader(testConfig))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TEST_USERS_HOST")
+
+	_, err = ParseConfig(strings.NewReader(`{"apis": {"GetUser": {"host": "users", "route": "GET /users/{id"}}}`))
+	assert.Error(t, err)
+}
+
+func Test_NewNamedFromConfig(t *testing.T) {
+	t.Setenv("TEST_ENV", "test")
+	t.Setenv("TEST_USERS_HOST", "https://users:9443")
+	config, err := ParseConfig(strings.NewReader(testConfig))
+	assert.NoError(t, err)
+
+	api, err := NewNamedFromConfig(config)
+	assert.NoError(t, err)
+	defer api.Close()
+
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "https", r.URL.Scheme)
+		assert.Equal(t, "users:9443", r.URL.Host)
+		assert.Equal(t, "/v1/users/1", r.URL.Path)
+		assert.Equal(t, "test", r.Header.Get("X-Env"))
+		deadline, ok := DeadlineFromHeader(r.Header)
+		assert.True(t, ok)
+		assert.True(t, time.Until(deadline) <= 500*time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	req, _ := request.NewRequest(context.Background(), "", nil, request.WithPathParams(map[string]string{"id": "1"}))
+	_, err = api.Call("GetUser", req)
+	assert.NoError(t, err)
+}
+
+func Test_WatchConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(config string, modified time.Time) {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(config), 0644))
+		assert.NoError(t, os.Chtimes(path, modified, modified))
+	}
+	write(`
+version: v1
+apis:
+  GetUser:
+    host: localhost:9999
+    route: GET /users/{id}
+`, time.Now().Add(-time.Minute))
+
+	api := NewNamed("")
+	watcher, err := api.WatchConfig(path, 10*time.Millisecond)
+	assert.NoError(t, err)
+	defer watcher.Close()
+
+	var paths []string
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	})
+	// added in code, kept across reloads
+	api.Add("GetAccount", "localhost:8888", WithRoute("GET /accounts/{id}"))
+	api.ExpectHook("GetAccount", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	get := func(apiName string) error {
+		req, _ := request.NewRequest(context.Background(), "", nil, request.WithPathParams(map[string]string{"id": "1"}))
+		_, err := api.Call(apiName, req)
+		return err
+	}
+	assert.NoError(t, get("GetUser"))
+
+	// reloaded with a new version and route, the hook is kept
+	write(`
+version: v2
+apis:
+  GetUser:
+    host: localhost:9999
+    route: GET /accounts/{id}
+  GetOrder:
+    host: localhost:9999
+    route: GET /orders/{id}
+`, time.Now())
+	assert.Eventually(t, func() bool {
+		api.lock.RLock()
+		defer api.lock.RUnlock()
+		return api.hosts.getHost("GetOrder") != ""
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, get("GetUser"))
+	assert.Equal(t, []string{"/v1/users/1", "/v2/accounts/1"}, paths)
+	assert.NoError(t, get("GetAccount"))
+
+	// an invalid config keeps the previous one
+	write(`apis: {GetUser: {route: GET /users}}`, time.Now().Add(time.Minute))
+	assert.Eventually(t, func() bool {
+		return watcher.Err() != nil
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, get("GetUser"))
+
+	// apis removed from the config are removed
+	write(`
+version: v2
+apis:
+  GetOrder:
+    host: localhost:9999
+    route: GET /orders/{id}
+`, time.Now().Add(2*time.Minute))
+	assert.Eventually(t, func() bool {
+		api.lock.RLock()
+		defer api.lock.RUnlock()
+		return api.hosts.getHost("GetUser") == ""
+	}, time.Second, 10*time.Millisecond)
+	assert.Error(t, get("GetUser"))
+	assert.NoError(t, get("GetAccount"))
+}
+
+type idleTransport struct {
+	http.RoundTripper
+	closed int
+}
+
+func (t *idleTransport) CloseIdleConnections() {
+	t.closed++
+}
+
+func Test_ApplyConfig(t *testing.T) {
+	transport := &idleTransport{RoundTripper: http.DefaultTransport}
+	api := NewNamed("", WithHttpClient(http.Client{Transport: transport}))
+	apply := func(config string) {
+		c, err := ParseConfig(strings.NewReader(config))
+		assert.NoError(t, err)
+		assert.NoError(t, api.ApplyConfig(c))
+	}
+	apply(`
+hosts:
+  users: {address: localhost:9999}
+apis:
+  GetUser: {host: users, route: GET /users, rateLimit: {rate: 1, burst: 1}}
+  GetOrder: {host: localhost:8888, route: GET /orders}
+`)
+	getUser, getOrder := api.apis["GetUser"], api.apis["GetOrder"]
+
+	// unchanged apis keep their state, replaced hosts sharing the transport leave it open
+	apply(`
+hosts:
+  users: {address: localhost:9999, basePath: users}
+apis:
+  GetUser: {host: users, route: GET /users, rateLimit: {rate: 1, burst: 1}}
+  GetOrder:
+    host: localhost:8888
+    route: GET /orders/{id}
+`)
+	assert.True(t, getUser == api.apis["GetUser"])
+	assert.False(t, getOrder == api.apis["GetOrder"])
+	assert.Equal(t, 0, transport.closed)
+}
@@ -0,0 +1,41 @@
+package internalApi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TimeoutHeader carries the remaining time (in milliseconds) before the caller's deadline
+const TimeoutHeader = "X-Request-Timeout"
+
+func setDeadlineHeader(header http.Header, deadline time.Time) {
+	remaining := time.Until(deadline).Milliseconds()
+	if remaining < 1 {
+		remaining = 1
+	}
+	header.Set(TimeoutHeader, strconv.FormatInt(remaining, 10))
+}
+
+// DeadlineFromHeader returns the deadline propagated by the caller via TimeoutHeader
+func DeadlineFromHeader(header http.Header) (time.Time, bool) {
+	ms, err := strconv.ParseInt(header.Get(TimeoutHeader), 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}, false
+	}
+	return time.Now().Add(time.Duration(ms) * time.Millisecond), true
+}
+
+// DeadlineMiddleware sets the deadline propagated by the caller on the incoming request's
+// context. Requests made with this context propagate the deadline further downstream
+func DeadlineMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if deadline, ok := DeadlineFromHeader(r.Header); ok {
+			ctx, cancel := context.WithDeadline(r.Context(), deadline)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,51 @@
+package internalApi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Timeout(t *testing.T) {
+	var timeouts = make(chan int64, 3)
+	s := httptest.NewServer(DeadlineMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms, _ := strconv.ParseInt(r.Header.Get(TimeoutHeader), 10, 64)
+		if _, ok := r.Context().Deadline(); ok {
+			timeouts <- ms
+		}
+		<-time.After(200 * time.Millisecond)
+	})))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	api := NewNamed("v1")
+	api.Add("Report", host, WithTimeout(50*time.Millisecond))
+
+	// apiName timeout
+	req, _ := request.NewRequest(context.Background(), "/report", nil)
+	_, err := api.Get("Report", req)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.LessOrEqual(t, <-timeouts, int64(50))
+
+	// per call override
+	req, _ = request.NewRequest(context.Background(), "/report", nil, request.WithTimeout(time.Second))
+	_, err = api.Get("Report", req)
+	assert.NoError(t, err)
+	assert.Greater(t, <-timeouts, int64(500))
+
+	// deadline from the incoming context
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	req, _ = request.NewRequest(ctx, "/report", nil, request.WithTimeout(time.Second))
+	_, err = api.Get("Report", req)
+	assert.Error(t, err)
+	assert.LessOrEqual(t, <-timeouts, int64(30))
+}
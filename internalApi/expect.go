@@ -0,0 +1,331 @@
+package internalApi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// TestingT is satisfied by *testing.T
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Expectation is a hook for an apiName responding to matching requests only, see NamedApi.Expect
+type Expectation struct {
+	apiName string
+
+	method    string
+	query     url.Values
+	headers   http.Header
+	body      func(body []byte) bool
+	operation string // graphql operation name
+
+	responses []expectedResponse // served in order, the last one repeats
+	times     int                // expected number of calls, 0 for at least once
+
+	calls int
+	mocks *mocks
+}
+
+type expectedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// mocks holds the expectations and the calls made to them
+type mocks struct {
+	lock         sync.Mutex
+	expectations map[string][]*Expectation // apiName : expectations
+	calls        []*Expectation            // in the order they were called
+	unexpected   []string
+	ordered      [][]*Expectation
+}
+
+func newMocks() *mocks {
+	return &mocks{expectations: map[string][]*Expectation{}}
+}
+
+// Expect adds an expectation for apiName, configure it before sending requests. Requests are
+// served by the first matching expectation that has calls left, requests matching none get
+// a 404 and fail AssertExpectations
+func (na *NamedApi) Expect(apiName string) *Expectation {
+	na.lock.Lock()
+	defer na.lock.Unlock()
+	host := na.hosts.getHost(apiName)
+	if host == "" {
+		panic("expecting an api hook which was not added " + apiName)
+	}
+
+	na.mocks.lock.Lock()
+	defer na.mocks.lock.Unlock()
+
+	if na.mocks.expectations[apiName] == nil {
+		if na.apiClient[host].hooks[apiName] != nil {
+			panic("already added hook " + apiName)
+		}
+		hook := na.mocks.hook(apiName)
+		na.apiClient[host].hooks[apiName] = &hook
+	}
+
+	e := &Expectation{apiName: apiName, query: url.Values{}, headers: http.Header{}, mocks: na.mocks}
+	na.mocks.expectations[apiName] = append(na.mocks.expectations[apiName], e)
+	return e
+}
+
+func (e *Expectation) Method(method string) *Expectation {
+	e.method = strings.ToUpper(method)
+	return e
+}
+
+func (e *Expectation) Query(key, val string) *Expectation {
+	e.query.Add(key, val)
+	return e
+}
+
+func (e *Expectation) Header(key, val string) *Expectation {
+	e.headers.Add(key, val)
+	return e
+}
+
+// Body matches requests for which match returns true
+func (e *Expectation) Body(match func(body []byte) bool) *Expectation {
+	e.body = match
+	return e
+}
+
+// JSONBody matches requests with a json body equal to v
+func (e *Expectation) JSONBody(v interface{}) *Expectation {
+	want, _ := json.Marshal(v)
+	want = normalizeJSON(want)
+	return e.Body(func(body []byte) bool {
+		return json.Valid(body) && bytes.Equal(want, normalizeJSON(body))
+	})
+}
+
+// Respond adds a response to the sequence, the last response repeats
+func (e *Expectation) Respond(status int, body []byte, headers ...map[string]string) *Expectation {
+	header := http.Header{}
+	for _, h := range headers {
+		for key, val := range h {
+			header.Set(key, val)
+		}
+	}
+	e.responses = append(e.responses, expectedResponse{status: status, header: header, body: body})
+	return e
+}
+
+// RespondJSON adds a json response to the sequence
+func (e *Expectation) RespondJSON(status int, v interface{}) *Expectation {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic("invalid json response " + err.Error())
+	}
+	return e.Respond(status, b, map[string]string{"Content-Type": "application/json"})
+}
+
+// Times sets the exact number of calls expected, once exhausted the expectation stops matching
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// Calls returns the number of requests served by the expectation
+func (e *Expectation) Calls() int {
+	e.mocks.lock.Lock()
+	defer e.mocks.lock.Unlock()
+	return e.calls
+}
+
+func (e *Expectation) String() string {
+	s := e.apiName
+	if e.method != "" {
+		s += " " + e.method
+	}
+	if len(e.query) > 0 {
+		s += " ?" + e.query.Encode()
+	}
+	if e.operation != "" {
+		s += " operation " + e.operation
+	}
+	return s
+}
+
+func (e *Expectation) matches(r *http.Request, body []byte) bool {
+	if e.times > 0 && e.calls >= e.times {
+		return false
+	}
+	if e.method != "" && e.method != r.Method {
+		return false
+	}
+	query := r.URL.Query()
+	for key, vals := range e.query {
+		for _, val := range vals {
+			if !contains(query[key], val) {
+				return false
+			}
+		}
+	}
+	for key, vals := range e.headers {
+		for _, val := range vals {
+			if !contains(r.Header.Values(key), val) {
+				return false
+			}
+		}
+	}
+	if e.operation != "" && e.operation != operationName(body) {
+		return false
+	}
+	return e.body == nil || e.body(body)
+}
+
+// re-marshal json so that formatting and key order do not matter
+func normalizeJSON(b []byte) []byte {
+	var v interface{}
+	if json.Unmarshal(b, &v) != nil {
+		return b
+	}
+	b, _ = json.Marshal(v)
+	return b
+}
+
+func contains(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// hook dispatching requests for apiName to its expectations
+func (m *mocks) hook(apiName string) HttpHook {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			body, _ = ioutil.ReadAll(r.Body)
+		}
+
+		m.lock.Lock()
+		var matched *Expectation
+		for _, e := range m.expectations[apiName] {
+			if e.matches(r, body) {
+				matched = e
+				break
+			}
+		}
+		if matched == nil {
+			m.unexpected = append(m.unexpected, fmt.Sprintf("%s %s %s", apiName, r.Method, r.URL.String()))
+			m.lock.Unlock()
+			http.Error(w, "unexpected call to "+apiName, http.StatusNotFound)
+			return
+		}
+		matched.calls++
+		m.calls = append(m.calls, matched)
+
+		rsp := expectedResponse{status: http.StatusOK}
+		if n := len(matched.responses); n > 0 {
+			rsp = matched.responses[n-1]
+			if matched.calls <= n {
+				rsp = matched.responses[matched.calls-1]
+			}
+		}
+		m.lock.Unlock()
+
+		for key, val := range rsp.header {
+			w.Header()[key] = val
+		}
+		w.WriteHeader(rsp.status)
+		_, _ = w.Write(rsp.body)
+	}
+}
+
+// InOrder expects the first call to each expectation to happen in the given order
+func (na *NamedApi) InOrder(expectations ...*Expectation) {
+	na.mocks.lock.Lock()
+	defer na.mocks.lock.Unlock()
+	na.mocks.ordered = append(na.mocks.ordered, expectations)
+}
+
+// AssertExpectations fails t for expectations not called as expected, calls matching no
+// expectation and calls out of order. Returns true if all expectations were met
+func (na *NamedApi) AssertExpectations(t TestingT) bool {
+	na.mocks.lock.Lock()
+	defer na.mocks.lock.Unlock()
+
+	ok := true
+	for _, expectations := range na.mocks.expectations {
+		for _, e := range expectations {
+			switch {
+			case e.times == 0 && e.calls == 0:
+				t.Errorf("expected call to %s, got none", e)
+				ok = false
+			case e.times > 0 && e.calls != e.times:
+				t.Errorf("expected %d call(s) to %s, got %d", e.times, e, e.calls)
+				ok = false
+			}
+		}
+	}
+	for _, call := range na.mocks.unexpected {
+		t.Errorf("unexpected call %s", call)
+		ok = false
+	}
+	for _, ordered := range na.mocks.ordered {
+		last, previous := -1, (*Expectation)(nil)
+		for _, e := range ordered {
+			first := -1
+			for i, call := range na.mocks.calls {
+				if call == e {
+					first = i
+					break
+				}
+			}
+			if first == -1 {
+				continue // reported as not called
+			}
+			if first < last {
+				t.Errorf("expected %s to be called after %s", e, previous)
+				ok = false
+			}
+			last, previous = first, e
+		}
+	}
+	return ok
+}
+
+// RemoveHook removes the hook and expectations for apiName, requests are sent to the host
+func (na *NamedApi) RemoveHook(apiName string) {
+	na.lock.Lock()
+	defer na.lock.Unlock()
+	na.mocks.lock.Lock()
+	defer na.mocks.lock.Unlock()
+	if client := na.apiClient[na.hosts.getHost(apiName)]; client != nil {
+		delete(client.hooks, apiName)
+		delete(client.grpcHooks, apiName)
+	}
+	delete(na.mocks.expectations, apiName)
+}
+
+// ResetHooks removes every hook and expectation along with their recorded calls
+func (na *NamedApi) ResetHooks() {
+	na.lock.Lock()
+	defer na.lock.Unlock()
+	na.mocks.lock.Lock()
+	defer na.mocks.lock.Unlock()
+	for _, client := range na.apiClient {
+		client.hooks = map[string]*HttpHook{}
+		client.grpcHooks = map[string]*GrpcHook{}
+	}
+	na.mocks.expectations = map[string][]*Expectation{}
+	na.mocks.calls, na.mocks.unexpected, na.mocks.ordered = nil, nil, nil
+}
@@ -0,0 +1,86 @@
+package internalApi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/Ishan27g/internalApi/test/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockT struct {
+	errors []string
+}
+
+func (m *mockT) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}
+
+func Test_Expectations(t *testing.T) {
+	api := NewNamed(server.Version)
+	api.Add(server.GetUserApi, "localhost:9999")
+	api.Add(server.AddUserApi, "localhost:9999")
+	client := &clientForX{api}
+
+	add := api.Expect(server.AddUserApi).Method(http.MethodPost).Query("user", "mock-user").
+		JSONBody(server.User{Name: "mock-user"}).Respond(http.StatusOK, nil).Once()
+	get := api.Expect(server.GetUserApi).Method(http.MethodGet).Header("Authorization", "Basic YW55Om9r").
+		RespondJSON(http.StatusOK, server.User{Name: "first"}).
+		RespondJSON(http.StatusOK, server.User{Name: "mock-user"})
+	api.InOrder(add, get)
+
+	assert.True(t, client.AddUser("mock-user"))
+	assert.Equal(t, "first", client.GetUser().Name)
+	assert.Equal(t, "mock-user", client.GetUser().Name)
+	assert.Equal(t, "mock-user", client.GetUser().Name)
+	assert.Equal(t, 3, get.Calls())
+	assert.True(t, api.AssertExpectations(t))
+
+	// exhausted
+	assert.False(t, client.AddUser("mock-user"))
+	m := &mockT{}
+	assert.False(t, api.AssertExpectations(m))
+	assert.Len(t, m.errors, 1)
+	assert.Contains(t, m.errors[0], "unexpected call")
+}
+
+func Test_Expectations_Unmet(t *testing.T) {
+	api := NewNamed(server.Version)
+	api.Add(server.GetUserApi, "localhost:9999")
+	api.Add(server.AddUserApi, "localhost:9999")
+	client := &clientForX{api}
+
+	get := api.Expect(server.GetUserApi).RespondJSON(http.StatusOK, server.User{Name: "mock-user"})
+	add := api.Expect(server.AddUserApi).Times(2)
+	api.InOrder(add, get)
+
+	client.GetUser()
+	assert.True(t, client.AddUser("mock-user"))
+
+	m := &mockT{}
+	assert.False(t, api.AssertExpectations(m))
+	assert.Len(t, m.errors, 2)
+
+	// fallback to a plain hook once removed
+	api.RemoveHook(server.AddUserApi)
+	api.ExpectHook(server.AddUserApi, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	assert.True(t, client.AddUser("any"))
+
+	api.ResetHooks()
+	assert.True(t, api.AssertExpectations(t))
+	api.Expect(server.AddUserApi).Body(func(body []byte) bool {
+		var user server.User
+		return json.Unmarshal(body, &user) == nil && strings.HasPrefix(user.Name, "user")
+	})
+	assert.True(t, client.AddUser("user1"))
+	req, _ := request.NewRequest(context.Background(), "/users", strings.NewReader(`{"Name":"other"}`))
+	_, err := api.Post(server.AddUserApi, req)
+	assert.Error(t, err)
+}
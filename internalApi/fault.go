@@ -0,0 +1,202 @@
+package internalApi
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Fault describes the failures injected into requests for an apiName, see NamedApi.InjectFault.
+// Rates are fractions of requests between 0 and 1
+type Fault struct {
+	// delay added before sending the request
+	Latency Latency
+
+	// requests answered with StatusCode (503 by default) without reaching the host
+	ErrorRate  float64
+	StatusCode int
+
+	// requests failing with a connection reset (syscall.ECONNRESET)
+	ResetRate float64
+
+	// responses whose body ends with io.ErrUnexpectedEOF halfway through, or after 512 bytes
+	// if its length is unknown
+	TruncateRate float64
+}
+
+// Latency returns the delay to add to a request
+type Latency func() time.Duration
+
+func FixedLatency(d time.Duration) Latency {
+	return func() time.Duration {
+		return d
+	}
+}
+
+// UniformLatency is uniformly distributed between min and max, swapped if max is below min.
+// Negative bounds are taken as zero
+func UniformLatency(min, max time.Duration) Latency {
+	if max < min {
+		min, max = max, min
+	}
+	if min < 0 {
+		min = 0
+	}
+	if max < 0 {
+		max = 0
+	}
+	return func() time.Duration {
+		if max-min == math.MaxInt64 {
+			return min + time.Duration(rand.Int63())
+		}
+		return min + time.Duration(rand.Int63n(int64(max-min)+1))
+	}
+}
+
+// NormalLatency is normally distributed, never below zero
+func NormalLatency(mean, stddev time.Duration) Latency {
+	return func() time.Duration {
+		return time.Duration(math.Max(0, rand.NormFloat64()*float64(stddev)+float64(mean)))
+	}
+}
+
+// ExponentialLatency is exponentially distributed with mean, eg. for long tails
+func ExponentialLatency(mean time.Duration) Latency {
+	return func() time.Duration {
+		return time.Duration(rand.ExpFloat64() * float64(mean))
+	}
+}
+
+// faults injected per apiName, can be changed while requests are in flight
+type faults struct {
+	lock    sync.RWMutex
+	enabled bool
+	faults  map[string]Fault // apiName : Fault
+	random  func() float64
+}
+
+func newFaults() *faults {
+	return &faults{enabled: true, faults: map[string]Fault{}, random: rand.Float64}
+}
+
+// InjectFault injects the fault into every request for apiName, replacing a previous fault
+func (na *NamedApi) InjectFault(apiName string, fault Fault) {
+	na.faults.lock.Lock()
+	defer na.faults.lock.Unlock()
+	na.faults.faults[apiName] = fault
+}
+
+// ClearFault stops injecting faults for apiName
+func (na *NamedApi) ClearFault(apiName string) {
+	na.faults.lock.Lock()
+	defer na.faults.lock.Unlock()
+	delete(na.faults.faults, apiName)
+}
+
+// EnableFaults toggles fault injection for every apiName without clearing the faults
+func (na *NamedApi) EnableFaults(enabled bool) {
+	na.faults.lock.Lock()
+	defer na.faults.lock.Unlock()
+	na.faults.enabled = enabled
+}
+
+func (f *faults) get(apiName string) (Fault, bool) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	fault, ok := f.faults[apiName]
+	return fault, ok && f.enabled
+}
+
+func (f *faults) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.random() < rate
+}
+
+// interceptor injecting the faults for apiName
+func (f *faults) interceptor(apiName string) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			fault, ok := f.get(apiName)
+			if !ok {
+				return next(req)
+			}
+
+			if fault.Latency != nil {
+				select {
+				case <-time.After(fault.Latency()):
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+			}
+
+			if f.roll(fault.ResetRate) {
+				return nil, fmt.Errorf("injected fault %s : %w", apiName, syscall.ECONNRESET)
+			}
+
+			if f.roll(fault.ErrorRate) {
+				status := fault.StatusCode
+				if status == 0 {
+					status = http.StatusServiceUnavailable
+				}
+				body := "injected fault " + apiName
+				return &http.Response{
+					Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+					StatusCode:    status,
+					Proto:         "HTTP/1.1",
+					ProtoMajor:    1,
+					ProtoMinor:    1,
+					Header:        http.Header{"Content-Type": {"text/plain"}},
+					Body:          ioutil.NopCloser(strings.NewReader(body)),
+					ContentLength: int64(len(body)),
+					Request:       req,
+				}, nil
+			}
+
+			resp, err := next(req)
+			if err == nil && f.roll(fault.TruncateRate) {
+				remaining := int64(truncatedLength)
+				if resp.ContentLength >= 0 {
+					remaining = resp.ContentLength / 2
+				}
+				resp.Body = &truncatedBody{ReadCloser: resp.Body, remaining: remaining}
+				resp.ContentLength = -1
+			}
+			return resp, err
+		}
+	}
+}
+
+// bytes of a body of unknown length read before it is truncated, eg. streamed responses
+const truncatedLength = 512
+
+// truncatedBody fails with io.ErrUnexpectedEOF after remaining bytes
+type truncatedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
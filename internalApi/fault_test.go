@@ -0,0 +1,94 @@
+package internalApi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Faults(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("GetUser", "localhost:9999")
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		_, _ = w.Write([]byte("0123456789"))
+	})
+
+	get := func(o ...request.Option) ([]byte, error) {
+		req, _ := request.NewRequest(context.Background(), "/users", nil, o...)
+		return api.Get("GetUser", req)
+	}
+
+	api.InjectFault("GetUser", Fault{ErrorRate: 1, StatusCode: http.StatusTooManyRequests})
+	_, err := get()
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode)
+
+	api.InjectFault("GetUser", Fault{ResetRate: 1})
+	_, err = get()
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+
+	api.InjectFault("GetUser", Fault{TruncateRate: 1})
+	_, err = get()
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+
+	// streamed bodies of unknown length are cut as they are read
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bytes.Repeat([]byte("a"), 1024))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+	api.Add("ListUsers", strings.TrimPrefix(s.URL, "http://"))
+	api.InjectFault("ListUsers", Fault{TruncateRate: 1})
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	stream, err := api.Stream("ListUsers", http.MethodGet, req)
+	assert.NoError(t, err)
+	b, err := ioutil.ReadAll(stream.Body)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	assert.Len(t, b, truncatedLength)
+	_ = stream.Body.Close()
+
+	api.InjectFault("GetUser", Fault{Latency: FixedLatency(time.Second)})
+	start := time.Now()
+	_, err = get(request.WithTimeout(20 * time.Millisecond))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// toggled at runtime
+	api.EnableFaults(false)
+	b, err = get(request.WithTimeout(20 * time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(b))
+
+	api.EnableFaults(true)
+	api.ClearFault("GetUser")
+	_, err = get()
+	assert.NoError(t, err)
+}
+
+func Test_Latency(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := UniformLatency(time.Millisecond, 2*time.Millisecond)()
+		assert.True(t, d >= time.Millisecond && d <= 2*time.Millisecond)
+		// swapped bounds
+		d = UniformLatency(2*time.Millisecond, time.Millisecond)()
+		assert.True(t, d >= time.Millisecond && d <= 2*time.Millisecond)
+		assert.GreaterOrEqual(t, UniformLatency(math.MinInt64, math.MaxInt64)(), time.Duration(0))
+		assert.GreaterOrEqual(t, NormalLatency(time.Millisecond, time.Millisecond)(), time.Duration(0))
+		assert.GreaterOrEqual(t, ExponentialLatency(time.Millisecond)(), time.Duration(0))
+	}
+}
@@ -0,0 +1,181 @@
+package internalApi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Ishan27g/internalApi/request"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+/*
+GraphQL
+- a GraphQL endpoint is added as a named api, eg. Add("Users", host, WithPath("/graphql"))
+- operations are POSTed as json, the same interceptors, tracing and hooks apply
+- hooks can match operations by name, see Expectation.Operation
+*/
+
+// GraphQLOperation is a query or mutation with its variables. OperationName defaults to the
+// name of the first operation in Query
+type GraphQLOperation struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is an error returned by the GraphQL server
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e GraphQLError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("%s (at %s)", e.Message, strings.Join(path, "."))
+}
+
+// GraphQLErrors are returned along with any partial data
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "graphql : " + strings.Join(msgs, "; ")
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+var graphQLOperation = regexp.MustCompile(`(?m)^\s*(query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// operation type and name of the first named operation in the query
+func parseOperation(query string) (string, string) {
+	m := graphQLOperation.FindStringSubmatch(query)
+	if m == nil {
+		return "query", ""
+	}
+	return m[1], m[2]
+}
+
+// GraphQL executes the operation on apiName and decodes its data into T. If the server
+// returns errors, they are returned as GraphQLErrors along with any partial data, including
+// for unsuccessful responses. Unsuccessful responses without errors return a StatusError
+func GraphQL[T any](ctx context.Context, na *NamedApi, apiName string, operation GraphQLOperation, options ...request.Option) (T, error) {
+	var data T
+
+	opType, opName := parseOperation(operation.Query)
+	if operation.OperationName == "" {
+		operation.OperationName = opName
+	}
+
+	name := "graphql " + apiName
+	if operation.OperationName != "" {
+		name += " " + operation.OperationName
+	}
+	ctx, span := na.parentSpan(ctx, name)
+	defer span.End()
+	span.SetAttributes(
+		attribute.Key("graphql.operation.type").String(opType),
+		attribute.Key("graphql.operation.name").String(operation.OperationName),
+	)
+
+	body, err := json.Marshal(operation)
+	if err != nil {
+		return data, err
+	}
+	req, err := request.NewRequest(withErrorBody(ctx), "", bytes.NewReader(body), options...)
+	if err != nil {
+		return data, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	resp, done, err := na.send(apiName, http.MethodPost, req, false)
+	if err != nil {
+		return data, err
+	}
+	var statusErr error
+	if resp.StatusCode > http.StatusAccepted {
+		statusErr = &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	rsp, err := readBody(withTrace(ctx, na.logger), resp, na.maxBodySizeFor(apiName))
+	if statusErr != nil {
+		err = statusErr
+	}
+	done(err)
+
+	var result graphQLResponse
+	if statusErr != nil {
+		// servers may return errors with a non-2xx status, else the status is returned
+		if json.Unmarshal(rsp, &result) != nil || len(result.Errors) == 0 {
+			return data, statusErr
+		}
+	} else if err != nil {
+		return data, err
+	} else if err := json.Unmarshal(rsp, &result); err != nil {
+		return data, fmt.Errorf("error decoding %s graphql response : %w", apiName, err)
+	}
+	if len(result.Data) > 0 && string(result.Data) != "null" {
+		if err := json.Unmarshal(result.Data, &data); err != nil {
+			return data, fmt.Errorf("error decoding %s graphql data : %w", apiName, err)
+		}
+	}
+	if len(result.Errors) > 0 {
+		span.SetStatus(codes.Error, result.Errors.Error())
+		return data, result.Errors
+	}
+	return data, nil
+}
+
+// Operation matches GraphQL requests for the named operation
+func (e *Expectation) Operation(name string) *Expectation {
+	e.operation = name
+	return e
+}
+
+// RespondGraphQL adds a GraphQL response with data and errors to the sequence
+func (e *Expectation) RespondGraphQL(data interface{}, errs ...GraphQLError) *Expectation {
+	rsp := map[string]interface{}{"data": data}
+	if len(errs) > 0 {
+		rsp["errors"] = errs
+	}
+	return e.RespondJSON(http.StatusOK, rsp)
+}
+
+// operation name of a GraphQL request body
+func operationName(body []byte) string {
+	var operation GraphQLOperation
+	if json.Unmarshal(body, &operation) != nil {
+		return ""
+	}
+	if operation.OperationName != "" {
+		return operation.OperationName
+	}
+	_, name := parseOperation(operation.Query)
+	return name
+}
@@ -0,0 +1,112 @@
+package internalApi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+type graphQLUser struct {
+	User struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+}
+
+const getUserQuery = `
+query GetUser($id: ID!) {
+	user(id: $id) { id name }
+}`
+
+func Test_GraphQL(t *testing.T) {
+	provider := newRecordingProvider()
+	api := NewNamed("", WithTracingProvider(provider))
+	api.Add("Users", "localhost:9999", WithPath("/graphql"))
+
+	getUser := api.Expect("Users").Operation("GetUser").RespondGraphQL(map[string]interface{}{
+		"user": map[string]interface{}{"id": "1", "name": "a"},
+	})
+	addUser := api.Expect("Users").Operation("AddUser").RespondGraphQL(nil, GraphQLError{
+		Message: "name is required", Path: []interface{}{"addUser", "name"},
+	})
+
+	user, err := GraphQL[graphQLUser](context.Background(), api, "Users", GraphQLOperation{
+		Query:     getUserQuery,
+		Variables: map[string]interface{}{"id": "1"},
+	}, request.WithBearerToken("token"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", user.User.Name)
+
+	_, err = GraphQL[json.RawMessage](context.Background(), api, "Users", GraphQLOperation{
+		Query:         `mutation { addUser(name: "") { id } }`,
+		OperationName: "AddUser",
+	})
+	var graphQLErrs GraphQLErrors
+	assert.True(t, errors.As(err, &graphQLErrs))
+	assert.Len(t, graphQLErrs, 1)
+	assert.Equal(t, "graphql : name is required (at addUser.name)", err.Error())
+
+	// operations without an expectation are not served
+	_, err = GraphQL[json.RawMessage](context.Background(), api, "Users", GraphQLOperation{Query: `query ListUsers { users { id } }`})
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+
+	assert.Equal(t, 1, getUser.Calls())
+	assert.Equal(t, 1, addUser.Calls())
+	assert.Len(t, provider.spans("graphql Users GetUser"), 1)
+}
+
+func Test_GraphQLRequest(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("Users", "localhost:9999", WithPath("/graphql"))
+	api.ExpectHook("Users", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/graphql", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		var operation GraphQLOperation
+		b, _ := ioutil.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(b, &operation))
+		assert.Equal(t, "GetUser", operation.OperationName)
+		assert.Equal(t, "1", operation.Variables["id"])
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"user": {"id": "1", "name": "a"}}}`))
+	})
+
+	user, err := GraphQL[graphQLUser](context.Background(), api, "Users", GraphQLOperation{
+		Query:     getUserQuery,
+		Variables: map[string]interface{}{"id": "1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", user.User.ID)
+}
+
+func Test_GraphQLErrorStatus(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("Users", "localhost:9999", WithPath("/graphql"))
+	api.Expect("Users").Operation("GetUser").
+		RespondJSON(http.StatusBadRequest, map[string]interface{}{
+			"errors": []GraphQLError{{Message: "unknown field"}},
+		}).
+		Respond(http.StatusBadGateway, []byte("bad gateway"))
+
+	// errors are decoded from unsuccessful responses
+	_, err := GraphQL[graphQLUser](context.Background(), api, "Users", GraphQLOperation{Query: getUserQuery})
+	var graphQLErrs GraphQLErrors
+	assert.True(t, errors.As(err, &graphQLErrs))
+	assert.Equal(t, "graphql : unknown field", err.Error())
+
+	// else the status is returned
+	_, err = GraphQL[graphQLUser](context.Background(), api, "Users", GraphQLOperation{Query: getUserQuery})
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusBadGateway, statusErr.StatusCode)
+}
@@ -0,0 +1,263 @@
+package internalApi
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+gRPC
+- a named api resolves to a gRPC method instead of an http path, see WithGrpcMethod
+- hosts, tls (AddHost), resolvers, timeouts and tracing are shared with http named apis
+- GrpcHook(s) serve the method in-process, like HttpHook(s)
+*/
+
+// GrpcHook serves a gRPC named api, returning the reply for req
+type GrpcHook func(ctx context.Context, req proto.Message) (proto.Message, error)
+
+// WithGrpcMethod sets the full gRPC method, eg. /grpc.health.v1.Health/Check, invoked by
+// NamedApi.Invoke for this apiName
+func WithGrpcMethod(fullMethod string) ApiOption {
+	return func(config *apiConfig) {
+		if !strings.HasPrefix(fullMethod, "/") {
+			fullMethod = "/" + fullMethod
+		}
+		config.grpcMethod = fullMethod
+	}
+}
+
+// WithGrpcDialOptions adds options used to dial gRPC hosts, eg. grpc.WithContextDialer for
+// a bufconn listener. Hosts are dialed without tls unless configured by AddHost
+func WithGrpcDialOptions(options ...grpc.DialOption) Option {
+	return func(api *NamedApi) {
+		api.grpcDialOptions = append(api.grpcDialOptions, options...)
+	}
+}
+
+// ExpectGrpcHook marks the gRPC named api as a mock hook
+func (na *NamedApi) ExpectGrpcHook(apiName string, hook GrpcHook) {
+	na.lock.Lock()
+	defer na.lock.Unlock()
+	host := na.hosts.getHost(apiName)
+	if host == "" {
+		panic("expecting an api hook which was not added " + apiName)
+	}
+	if na.apiClient[host].grpcHooks[apiName] != nil {
+		panic("already added hook " + apiName)
+	}
+	na.apiClient[host].grpcHooks[apiName] = &hook
+}
+
+// Invoke calls the gRPC method of apiName with req, unmarshalling the response into reply.
+// Errors are gRPC status errors, see status.FromError
+func (na *NamedApi) Invoke(ctx context.Context, apiName string, req, reply proto.Message, options ...grpc.CallOption) (err error) {
+	na.lock.RLock()
+	host := na.hosts.getHost(apiName)
+	client := na.apiClient[host]
+	config := na.apis[apiName]
+	var hook *GrpcHook
+	var tlsConfig *tls.Config
+	if client != nil {
+		hook, tlsConfig = client.grpcHooks[apiName], client.tls
+	}
+	na.lock.RUnlock()
+	if client == nil || config == nil {
+		return errors.New(fmt.Sprintf("%s NamedApi not added", apiName))
+	}
+	if config.err != nil {
+		return fmt.Errorf("%s NamedApi : %v", apiName, config.err)
+	}
+	if config.grpcMethod == "" {
+		return fmt.Errorf("%s NamedApi added without a grpc method", apiName)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if na.tracingProvider != nil {
+		ctx, span = na.tracingProvider.Get().Start(ctx, apiName, trace.WithSpanKind(trace.SpanKindClient))
+	}
+	defer span.End()
+	service, method := splitGrpcMethod(config.grpcMethod)
+	span.SetAttributes(
+		attribute.Key("rpc.system").String("grpc"),
+		attribute.Key("rpc.service").String(service),
+		attribute.Key("rpc.method").String(method),
+	)
+
+	if config.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.timeout)
+		defer cancel()
+	}
+
+	logger := withTrace(ctx, na.logger)
+	logFields := []interface{}{"method", config.grpcMethod, "api", apiName}
+	defer func() {
+		code := status.Code(err)
+		span.SetAttributes(attribute.Key("rpc.grpc.status_code").Int(int(code)))
+		if err != nil {
+			span.SetStatus(otelcodes.Error, err.Error())
+			logger.Error("error invoking method", append(logFields, "error", err)...)
+		}
+	}()
+
+	// if hooks is set
+	if hook != nil {
+		span.SetAttributes(attribute.Key("api-hooked?").Bool(true))
+		logger.Debug("hooked", logFields...)
+		rsp, err := (*hook)(ctx, req)
+		if err != nil {
+			return err
+		}
+		// the reply must be of the method's type
+		if rsp == nil || !rsp.ProtoReflect().IsValid() {
+			return status.Errorf(codes.Internal, "%s hook returned no reply", apiName)
+		}
+		if rsp.ProtoReflect().Descriptor() != reply.ProtoReflect().Descriptor() {
+			return status.Errorf(codes.Internal, "%s hook returned %s, expected %s", apiName,
+				rsp.ProtoReflect().Descriptor().FullName(), reply.ProtoReflect().Descriptor().FullName())
+		}
+		proto.Reset(reply)
+		proto.Merge(reply, rsp)
+		return nil
+	}
+	span.SetAttributes(attribute.Key("api-hooked?").Bool(false))
+
+	target := host
+	if config.pool != nil {
+		e, pickErr := config.pool.pick()
+		if pickErr != nil {
+			return status.Errorf(codes.Unavailable, "%s NamedApi no endpoints : %v", apiName, pickErr)
+		}
+		target = e.host
+		defer func() {
+			config.pool.done(e, isGrpcEndpointFailure(err))
+		}()
+	}
+
+	conn, err := client.grpcConn(target, tlsConfig, na.grpcDialOptions)
+	if err != nil {
+		return err
+	}
+
+	// propagate the trace to the host
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	otel.GetTextMapPropagator().Inject(ctx, grpcMetadataCarrier(md))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	logger.Debug("invoking method", logFields...)
+	return conn.Invoke(ctx, config.grpcMethod, req, reply, options...)
+}
+
+// get or dial the connection to target
+func (c *apiClient) grpcConn(target string, tlsConfig *tls.Config, options []grpc.DialOption) (*grpc.ClientConn, error) {
+	c.grpcLock.Lock()
+	defer c.grpcLock.Unlock()
+	if conn := c.grpcConns[target]; conn != nil {
+		return conn, nil
+	}
+
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.Dial(target, append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, options...)...)
+	if err != nil {
+		return nil, err
+	}
+	if c.grpcConns == nil {
+		c.grpcConns = map[string]*grpc.ClientConn{}
+	}
+	c.grpcConns[target] = conn
+	return conn, nil
+}
+
+// Close closes the gRPC connections to every host and writes the recorded cassettes, see WithRecording
+func (na *NamedApi) Close() error {
+	na.lock.RLock()
+	defer na.lock.RUnlock()
+	var errs []string
+	for _, client := range na.apiClient {
+		if err := client.closeGrpc(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if na.recorder != nil {
+		if err := na.recorder.flush(); err != nil {
+			return fmt.Errorf("error writing cassettes : %v", err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New("error closing grpc connections : " + strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func (c *apiClient) closeGrpc() error {
+	c.grpcLock.Lock()
+	defer c.grpcLock.Unlock()
+	var errs []string
+	for target, conn := range c.grpcConns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, target+" : "+err.Error())
+		}
+	}
+	c.grpcConns = nil
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// split /package.Service/Method
+func splitGrpcMethod(fullMethod string) (string, string) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service, method
+}
+
+// unavailable hosts are ejected from the endpoint pool
+func isGrpcEndpointFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.Unknown:
+		return !errors.Is(err, context.Canceled)
+	}
+	return false
+}
+
+// grpcMetadataCarrier adapts metadata.MD to propagation.TextMapCarrier
+type grpcMetadataCarrier metadata.MD
+
+func (c grpcMetadataCarrier) Get(key string) string {
+	vals := metadata.MD(c).Get(key)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
+
+func (c grpcMetadataCarrier) Set(key, val string) {
+	metadata.MD(c).Set(key, val)
+}
+
+func (c grpcMetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,116 @@
+package internalApi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+const healthCheck = "/grpc.health.v1.Health/Check"
+
+// in-process grpc server with the health service
+func bufconnServer(t *testing.T) (*health.Server, grpc.DialOption) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	return healthServer, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	})
+}
+
+func Test_Grpc(t *testing.T) {
+	healthServer, dialer := bufconnServer(t)
+	healthServer.SetServingStatus("users", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	provider := newRecordingProvider()
+	api := NewNamed("v1", WithTracingProvider(provider), WithGrpcDialOptions(dialer))
+	defer api.Close()
+	api.Add("Health", "bufnet", WithGrpcMethod(healthCheck), WithTimeout(time.Second))
+	api.Add("Unknown", "bufnet", WithGrpcMethod("/users.Users/Get"))
+
+	var rsp healthpb.HealthCheckResponse
+	assert.NoError(t, api.Invoke(context.Background(), "Health", &healthpb.HealthCheckRequest{}, &rsp))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, rsp.Status)
+
+	assert.NoError(t, api.Invoke(context.Background(), "Health", &healthpb.HealthCheckRequest{Service: "users"}, &rsp))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, rsp.Status)
+
+	err := api.Invoke(context.Background(), "Health", &healthpb.HealthCheckRequest{Service: "orders"}, &rsp)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// replies that are missing or of another type
+	api.Add("Empty", "localhost:50051", WithGrpcMethod(healthCheck))
+	api.ExpectGrpcHook("Empty", func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return nil, nil
+	})
+	err = api.Invoke(context.Background(), "Empty", &healthpb.HealthCheckRequest{}, &rsp)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	api.Add("Mismatch", "localhost:50051", WithGrpcMethod(healthCheck))
+	api.ExpectGrpcHook("Mismatch", func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return &healthpb.HealthCheckRequest{Service: "users"}, nil
+	})
+	err = api.Invoke(context.Background(), "Mismatch", &healthpb.HealthCheckRequest{}, &rsp)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.ErrorContains(t, err, "grpc.health.v1.HealthCheckRequest")
+
+	err = api.Invoke(context.Background(), "Unknown", &healthpb.HealthCheckRequest{}, &rsp)
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	spans := provider.spans("Health")
+	assert.Len(t, spans, 3)
+
+	// http named apis cannot be invoked
+	api.Add("GetUser", "localhost:9999")
+	assert.Error(t, api.Invoke(context.Background(), "GetUser", &healthpb.HealthCheckRequest{}, &rsp))
+}
+
+func Test_GrpcHook(t *testing.T) {
+	api := NewNamed("v1")
+	defer api.Close()
+	api.Add("Health", "localhost:50051", WithGrpcMethod(healthCheck))
+	api.Add("GetUser", "localhost:50051")
+
+	api.ExpectGrpcHook("Health", func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		if req.(*healthpb.HealthCheckRequest).Service == "orders" {
+			return nil, status.Error(codes.NotFound, "unknown service")
+		}
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+	})
+	// http and grpc named apis share the host
+	api.Expect("GetUser")
+
+	var rsp healthpb.HealthCheckResponse
+	assert.NoError(t, api.Invoke(context.Background(), "Health", &healthpb.HealthCheckRequest{Service: "users"}, &rsp))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, rsp.Status)
+
+	err := api.Invoke(context.Background(), "Health", &healthpb.HealthCheckRequest{Service: "orders"}, &rsp)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Get("GetUser", req)
+	assert.NoError(t, err)
+
+	api.RemoveHook("Health")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = api.Invoke(ctx, "Health", &healthpb.HealthCheckRequest{}, &rsp, grpc.WaitForReady(true))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded)
+}
@@ -0,0 +1,492 @@
+package internalApi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+HAR
+- WithHAR records the exchanges of every apiName, HARRecorder.WriteFile writes them as a
+  HAR 1.2 file that can be opened in browser dev tools
+- sensitive headers, query params and body fields are redacted
+*/
+
+// Redacted replaces redacted values
+const Redacted = "REDACTED"
+
+// HAR is the root of a HAR 1.2 file
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"` // ms
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ApiName         string      `json:"_apiName"`
+	Error           string      `json:"_error,omitempty"`
+
+	started time.Time
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARPostData struct {
+	MimeType         string `json:"mimeType"`
+	Text             string `json:"text"`
+	Encoding         string `json:"_encoding,omitempty"`
+	Truncated        bool   `json:"_truncated,omitempty"`
+	RedactionSkipped bool   `json:"_redactionSkipped,omitempty"` // the text is left out
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size             int    `json:"size"`
+	MimeType         string `json:"mimeType"`
+	Text             string `json:"text,omitempty"`
+	Encoding         string `json:"encoding,omitempty"`
+	Truncated        bool   `json:"_truncated,omitempty"`
+	RedactionSkipped bool   `json:"_redactionSkipped,omitempty"` // the text is left out
+}
+
+// HARTimings in ms, -1 when not measured
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder records exchanges in memory, see WithHAR
+type HARRecorder struct {
+	headers     map[string]bool // canonical header names
+	query       map[string]bool
+	fields      map[string]bool // lower case json or form field names
+	maxBodySize int
+	maxEntries  int
+
+	lock    sync.Mutex
+	entries []HAREntry
+}
+
+type HAROption func(*HARRecorder)
+
+// WithRedactedHeaders redacts the headers, in addition to Authorization, Cookie,
+// Set-Cookie and Proxy-Authorization
+func WithRedactedHeaders(headers ...string) HAROption {
+	return func(h *HARRecorder) {
+		for _, header := range headers {
+			h.headers[http.CanonicalHeaderKey(header)] = true
+		}
+	}
+}
+
+// WithRedactedQuery redacts the query params
+func WithRedactedQuery(params ...string) HAROption {
+	return func(h *HARRecorder) {
+		for _, param := range params {
+			h.query[param] = true
+		}
+	}
+}
+
+// WithRedactedFields redacts json fields, at any depth, and form fields with these names.
+// Names are case-insensitive
+func WithRedactedFields(fields ...string) HAROption {
+	return func(h *HARRecorder) {
+		for _, field := range fields {
+			h.fields[strings.ToLower(field)] = true
+		}
+	}
+}
+
+// WithHARMaxBodySize truncates bodies over size bytes, 1MB by default
+func WithHARMaxBodySize(size int) HAROption {
+	return func(h *HARRecorder) {
+		h.maxBodySize = size
+	}
+}
+
+// WithHARMaxEntries keeps the last n entries only
+func WithHARMaxEntries(n int) HAROption {
+	return func(h *HARRecorder) {
+		h.maxEntries = n
+	}
+}
+
+func NewHARRecorder(options ...HAROption) *HARRecorder {
+	h := &HARRecorder{headers: map[string]bool{}, query: map[string]bool{}, fields: map[string]bool{}, maxBodySize: 1 << 20}
+	for _, header := range unrecordedHeaders {
+		h.headers[header] = true
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// HAR returns the recorded entries ordered by start time
+func (h *HARRecorder) HAR() *HAR {
+	h.lock.Lock()
+	entries := append([]HAREntry{}, h.entries...)
+	h.lock.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].started.Before(entries[j].started)
+	})
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "internalApi", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+// WriteTo writes the HAR as json
+func (h *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(h.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// WriteFile writes the HAR to path, eg. debug.har
+func (h *HARRecorder) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := h.WriteTo(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Reset drops the recorded entries
+func (h *HARRecorder) Reset() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.entries = nil
+}
+
+func (h *HARRecorder) add(entry HAREntry) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.entries = append(h.entries, entry)
+	if h.maxEntries > 0 && len(h.entries) > h.maxEntries {
+		h.entries = h.entries[len(h.entries)-h.maxEntries:]
+	}
+}
+
+// interceptor recording the exchanges for apiName
+func (h *HARRecorder) interceptor(apiName string) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			// the request body is kept as it is sent
+			var reqBody *harBody
+			if req.Body != nil && req.Body != http.NoBody {
+				reqBody = &harBody{ReadCloser: req.Body, max: h.maxBodySize}
+				req.Body = reqBody
+			}
+			contentType := req.Header.Get("Content-Type")
+
+			started := time.Now()
+			entry := HAREntry{
+				StartedDateTime: started.Format("2006-01-02T15:04:05.000Z07:00"),
+				ApiName:         apiName,
+				Request:         h.request(req),
+				Timings:         HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+				started:         started,
+			}
+			sent := func() {
+				if reqBody != nil {
+					body, size := reqBody.captured()
+					entry.Request.BodySize = size
+					entry.Request.PostData = h.postData(contentType, body, size)
+				}
+			}
+
+			resp, err := next(req)
+			wait := time.Since(started)
+			entry.Timings.Wait = ms(wait)
+			if err != nil {
+				sent()
+				entry.Time = ms(wait)
+				entry.Error = err.Error()
+				entry.Response = HARResponse{HTTPVersion: "HTTP/1.1", Cookies: []HARNameValue{}, Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1}
+				h.add(entry)
+				return resp, err
+			}
+
+			// the entry is complete once the body is read
+			resp.Body = &harBody{ReadCloser: resp.Body, max: h.maxBodySize, done: func(body []byte, size int) {
+				sent()
+				total := time.Since(started)
+				entry.Time = ms(total)
+				entry.Timings.Receive = ms(total - wait)
+				entry.Response = h.response(resp, body, size)
+				h.add(entry)
+			}}
+			return resp, nil
+		}
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (h *HARRecorder) headerValues(header http.Header) []HARNameValue {
+	values := []HARNameValue{}
+	for name, vals := range header {
+		for _, val := range vals {
+			if h.headers[http.CanonicalHeaderKey(name)] {
+				val = Redacted
+			}
+			values = append(values, HARNameValue{Name: name, Value: val})
+		}
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Name < values[j].Name
+	})
+	return values
+}
+
+func (h *HARRecorder) request(req *http.Request) HARRequest {
+	u := *req.URL
+	query := u.Query()
+	queryString := []HARNameValue{}
+	for name, vals := range query {
+		for i := range vals {
+			if h.query[name] {
+				vals[i] = Redacted
+			}
+			queryString = append(queryString, HARNameValue{Name: name, Value: vals[i]})
+		}
+	}
+	sort.SliceStable(queryString, func(i, j int) bool {
+		return queryString[i].Name < queryString[j].Name
+	})
+	u.RawQuery = query.Encode()
+
+	return HARRequest{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     h.headerValues(req.Header),
+		QueryString: queryString,
+		HeadersSize: -1,
+	}
+}
+
+// post data of the request body that was sent, size bytes of which body was kept
+func (h *HARRecorder) postData(contentType string, body []byte, size int) *HARPostData {
+	if size == 0 {
+		return nil
+	}
+	text, encoding, truncated, skipped := h.body(body, contentType, size > len(body))
+	return &HARPostData{MimeType: contentType, Text: text, Encoding: encoding, Truncated: truncated, RedactionSkipped: skipped}
+}
+
+func (h *HARRecorder) response(resp *http.Response, body []byte, size int) HARResponse {
+	contentType := resp.Header.Get("Content-Type")
+	// the body is cut to the max body size when it is read
+	text, encoding, truncated, skipped := h.body(body, contentType, size > len(body))
+	// resp.Status is eg. 200 OK
+	statusText := strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)))
+	if statusText == "" {
+		statusText = http.StatusText(resp.StatusCode)
+	}
+	return HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  statusText,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     h.headerValues(resp.Header),
+		Content:     HARContent{Size: size, MimeType: contentType, Text: text, Encoding: encoding, Truncated: truncated, RedactionSkipped: skipped},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    size,
+	}
+}
+
+// body as text, base64 encoded if it is not utf8. The body is redacted before it is
+// truncated to the max body size, a truncated body that can't be redacted is left out
+func (h *HARRecorder) body(b []byte, contentType string, truncated bool) (text, encoding string, isTruncated, skipped bool) {
+	redacted, ok := h.redact(b, contentType)
+	switch {
+	case ok:
+		b = redacted
+	case contentType == "" && !truncated:
+		// not json after all
+	default:
+		return "", "", truncated, true
+	}
+	if h.maxBodySize > 0 && len(b) > h.maxBodySize {
+		b, truncated = b[:h.maxBodySize], true
+	}
+	if utf8.Valid(b) {
+		return string(b), "", truncated, false
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64", truncated, false
+}
+
+// redact json and form fields, false if the body can't be parsed
+func (h *HARRecorder) redact(body []byte, contentType string) ([]byte, bool) {
+	if len(h.fields) == 0 || len(body) == 0 {
+		return body, true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, false
+		}
+		for name, vals := range values {
+			if h.fields[strings.ToLower(name)] {
+				for i := range vals {
+					vals[i] = Redacted
+				}
+			}
+		}
+		return []byte(values.Encode()), true
+	case isJSON(contentType):
+		if !json.Valid(body) {
+			return nil, false
+		}
+		var v interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if decoder.Decode(&v) != nil {
+			return nil, false
+		}
+		b, err := json.Marshal(h.redactJSON(v))
+		if err != nil {
+			return nil, false
+		}
+		return b, true
+	}
+	return body, true
+}
+
+func (h *HARRecorder) redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if h.fields[strings.ToLower(key)] {
+				v[key] = Redacted
+			} else {
+				v[key] = h.redactJSON(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = h.redactJSON(val)
+		}
+	}
+	return v
+}
+
+// harBody keeps up to max bytes of the body, done is called once it is closed if set.
+// Request bodies are read by the transport while the response is handled
+type harBody struct {
+	io.ReadCloser
+	max  int
+	lock sync.Mutex
+	buf  bytes.Buffer
+	size int
+	done func(body []byte, size int)
+	once sync.Once
+}
+
+// bytes kept and size of the body read so far
+func (b *harBody) captured() ([]byte, int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...), b.size
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.size += n
+	if keep := b.max - b.buf.Len(); keep > 0 || b.max <= 0 {
+		if b.max > 0 && n > keep {
+			b.buf.Write(p[:keep])
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		if b.done != nil {
+			b.done(b.captured())
+		}
+	})
+	return err
+}
@@ -0,0 +1,166 @@
+package internalApi
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HAR(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		switch r.URL.Path {
+		case "/v1/login":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"user":{"name":"a","token":"secret"}}`))
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte{0xff, 0xfe})
+		}
+	}))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	har := NewHARRecorder(WithRedactedHeaders("X-Api-Key"), WithRedactedQuery("key"), WithRedactedFields("password", "token"))
+	api := NewNamed("v1", WithHAR(har))
+	api.Add("Login", host, WithRoute("POST /login"))
+	api.Add("Download", host, WithRoute("GET /download"))
+
+	req, _ := request.NewRequest(context.Background(), "", strings.NewReader(`{"name":"a","password":"secret"}`),
+		request.WithHeaders(map[string][]string{"X-Api-Key": {"secret"}, "Content-Type": {"application/json"}}),
+		request.WithQueryParams(map[string]string{"key": "secret", "page": "1"}))
+	_, err := api.Call("Login", req)
+	assert.NoError(t, err)
+	req, _ = request.NewRequest(context.Background(), "", nil)
+	_, err = api.Call("Download", req)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "debug.har")
+	assert.NoError(t, har.WriteFile(path))
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+
+	var h HAR
+	assert.NoError(t, json.Unmarshal(b, &h))
+	assert.Equal(t, "1.2", h.Log.Version)
+	assert.Len(t, h.Log.Entries, 2)
+
+	login := h.Log.Entries[0]
+	assert.Equal(t, "Login", login.ApiName)
+	assert.Equal(t, http.MethodPost, login.Request.Method)
+	u, _ := url.Parse(login.Request.URL)
+	assert.Equal(t, "/v1/login", u.Path)
+	assert.Equal(t, Redacted, u.Query().Get("key"))
+	assert.Contains(t, login.Request.QueryString, HARNameValue{Name: "page", Value: "1"})
+	assert.Contains(t, login.Request.Headers, HARNameValue{Name: "X-Api-Key", Value: Redacted})
+	assert.JSONEq(t, `{"name":"a","password":"REDACTED"}`, login.Request.PostData.Text)
+	assert.Equal(t, http.StatusOK, login.Response.Status)
+	assert.Equal(t, "OK", login.Response.StatusText)
+	assert.Contains(t, login.Response.Headers, HARNameValue{Name: "Set-Cookie", Value: Redacted})
+	assert.JSONEq(t, `{"user":{"name":"a","token":"REDACTED"}}`, login.Response.Content.Text)
+	assert.True(t, login.Time >= login.Timings.Wait)
+
+	download := h.Log.Entries[1]
+	assert.Nil(t, download.Request.PostData)
+	assert.Equal(t, "base64", download.Response.Content.Encoding)
+	assert.Equal(t, "//4=", download.Response.Content.Text)
+	assert.Equal(t, 2, download.Response.Content.Size)
+
+	// failed exchanges are recorded with the error
+	s.Close()
+	har.Reset()
+	_, err = api.Call("Download", req)
+	assert.Error(t, err)
+	entries := har.HAR().Log.Entries
+	assert.Len(t, entries, 1)
+	assert.NotEmpty(t, entries[0].Error)
+	assert.Equal(t, 0, entries[0].Response.Status)
+}
+
+func Test_HARMaxEntries(t *testing.T) {
+	har := NewHARRecorder(WithHARMaxEntries(2), WithHARMaxBodySize(4))
+	api := NewNamed("v1", WithHAR(har))
+	api.Add("GetUser", "localhost:9999")
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(r.URL.Query().Get("id") + "-user"))
+	})
+	for _, id := range []string{"1", "2", "3"} {
+		req, _ := request.NewRequest(context.Background(), "/users", nil, request.WithQueryParams(map[string]string{"id": id}))
+		_, err := api.Call("GetUser", req)
+		assert.NoError(t, err)
+	}
+	entries := har.HAR().Log.Entries
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "2-us", entries[0].Response.Content.Text)
+	assert.Equal(t, 6, entries[0].Response.Content.Size)
+	assert.Equal(t, "3-us", entries[1].Response.Content.Text)
+}
+
+func Test_HARTruncatedRedaction(t *testing.T) {
+	har := NewHARRecorder(WithRedactedFields("password"), WithHARMaxBodySize(50))
+	api := NewNamed("v1", WithHAR(har))
+	api.Add("Login", "localhost:9999")
+	body := `{"name":"` + strings.Repeat("a", 40) + `","password":"hunter2"}`
+	api.ExpectHook("Login", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(body))
+	})
+	req, _ := request.NewRequest(context.Background(), "/login", strings.NewReader(body), request.WithHeaders(map[string][]string{"Content-Type": {"application/json"}}))
+	_, err := api.Call("Login", req)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(har.HAR())
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "hunter2")
+
+	entry := har.HAR().Log.Entries[0]
+	// bodies are truncated as they are read, they can't be redacted
+	assert.True(t, entry.Request.PostData.Truncated)
+	assert.True(t, entry.Request.PostData.RedactionSkipped)
+	assert.Empty(t, entry.Request.PostData.Text)
+	assert.Equal(t, len(body), entry.Request.BodySize)
+	assert.True(t, entry.Response.Content.RedactionSkipped)
+	assert.True(t, entry.Response.Content.Truncated)
+	assert.Empty(t, entry.Response.Content.Text)
+	assert.Equal(t, len(body), entry.Response.Content.Size)
+}
+
+func Test_HARStreamedRequest(t *testing.T) {
+	har := NewHARRecorder(WithHARMaxBodySize(16))
+	api := NewNamed("v1", WithHAR(har))
+	api.Add("Upload", "localhost:9999", WithRoute("POST /files"), WithCompression(Compression{MinSize: 1}))
+	var encoding string
+	api.ExpectHook("Upload", func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		_, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// the multipart body is still streamed and compressed as it is sent
+	content := strings.Repeat("a", 4096)
+	req, err := request.NewRequest(context.Background(), "", nil,
+		request.WithMultipart(request.ReaderPart("file", "a.txt", "text/plain", strings.NewReader(content))))
+	assert.NoError(t, err)
+	_, err = api.Call("Upload", req)
+	assert.NoError(t, err)
+	assert.Nil(t, req.Request.GetBody)
+	assert.Equal(t, "gzip", encoding)
+
+	entry := har.HAR().Log.Entries[0]
+	assert.Greater(t, entry.Request.BodySize, len(content))
+	assert.Len(t, entry.Request.PostData.Text, 16)
+	assert.True(t, entry.Request.PostData.Truncated)
+}
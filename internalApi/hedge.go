@@ -0,0 +1,178 @@
+package internalApi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// start a span parenting the requests made for apiName
+func (na *NamedApi) parentSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	span := trace.SpanFromContext(ctx)
+	if na.tracingProvider != nil {
+		ctx, span = na.tracingProvider.Get().Start(ctx, name)
+	}
+	return ctx, span
+}
+
+// Hedged sends the request and, if it has not succeeded after delay, sends it again, up to
+// maxAttempts requests in total. A failed request starts the next attempt right away, unless
+// it failed with a status below 500 which is returned. The first successful response is
+// returned and the other requests are cancelled.
+// Only GET, HEAD and OPTIONS requests, or requests with an Idempotency-Key header, are
+// hedged, others are sent once
+func (na *NamedApi) Hedged(apiName string, method string, req *request.Request, delay time.Duration, maxAttempts int) ([]byte, error) {
+	if maxAttempts < 1 || !isHedgeable(method, req) {
+		maxAttempts = 1
+	}
+
+	ctx, span := na.parentSpan(req.Request.Context(), "hedged "+apiName)
+	defer span.End()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		attempt int
+		rsp     []byte
+		err     error
+	}
+	results := make(chan result, maxAttempts)
+
+	attempt := func(n int) error {
+		r, err := req.Clone(ctx)
+		if err != nil {
+			return err
+		}
+		go func() {
+			rsp, err := na.do(apiName, method, r)
+			results <- result{attempt: n, rsp: rsp, err: err}
+		}()
+		return nil
+	}
+
+	if err := attempt(1); err != nil {
+		return nil, err
+	}
+	sent, pending := 1, 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if sent < maxAttempts {
+				sent++
+				if err := attempt(sent); err != nil {
+					return nil, err
+				}
+				pending++
+				timer.Reset(delay)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				span.SetAttributes(attribute.Key("hedge-attempts").Int(sent), attribute.Key("hedge-winner").Int(r.attempt))
+				return r.rsp, nil
+			}
+			lastErr = r.err
+			var statusErr *StatusError
+			if errors.As(r.err, &statusErr) && statusErr.StatusCode < http.StatusInternalServerError {
+				// the other requests would get the same response
+				pending = 0
+				break
+			}
+			if sent < maxAttempts {
+				sent++
+				if err := attempt(sent); err != nil {
+					return nil, err
+				}
+				pending++
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	span.SetAttributes(attribute.Key("hedge-attempts").Int(sent))
+	span.SetStatus(codes.Error, lastErr.Error())
+	return nil, lastErr
+}
+
+// requests that can be sent more than once concurrently
+func isHedgeable(method string, req *request.Request) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return req.Request.Header.Get("Idempotency-Key") != ""
+}
+
+// Call is a request for FanOut
+type Call struct {
+	ApiName string
+	Method  string
+	Request *request.Request
+}
+
+// Result of a Call, Value is decoded from the json response
+type Result[T any] struct {
+	ApiName string
+	Value   T
+	Err     error
+}
+
+// FanOut sends the calls concurrently and returns their results in the same order. Requests
+// are sent with ctx, under a common span, and are cancelled if ctx is done
+func FanOut[T any](ctx context.Context, na *NamedApi, calls ...Call) []Result[T] {
+	ctx, span := na.parentSpan(ctx, "fan-out")
+	defer span.End()
+
+	results := make([]Result[T], len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		results[i].ApiName = call.ApiName
+		if call.Request == nil {
+			results[i].Err = errors.New("missing request for " + call.ApiName)
+			continue
+		}
+		r, err := call.Request.Clone(ctx)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int, call Call, r *request.Request) {
+			defer wg.Done()
+			rsp, err := na.do(call.ApiName, call.Method, r)
+			if err == nil && len(rsp) > 0 {
+				if jsonErr := json.Unmarshal(rsp, &results[i].Value); jsonErr != nil {
+					err = fmt.Errorf("error decoding %s response : %w", call.ApiName, jsonErr)
+				}
+			}
+			results[i].Err = err
+		}(i, call, r)
+	}
+	wg.Wait()
+
+	var failed int
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	span.SetAttributes(attribute.Key("fan-out-calls").Int(len(calls)), attribute.Key("fan-out-failed").Int(failed))
+	if failed > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d calls failed", failed, len(calls)))
+	}
+	return results
+}
@@ -0,0 +1,148 @@
+package internalApi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// in-memory tracing.TraceProvider
+type recordingProvider struct {
+	*sdktrace.TracerProvider
+	recorder *tracetest.SpanRecorder
+}
+
+func newRecordingProvider() *recordingProvider {
+	recorder := tracetest.NewSpanRecorder()
+	return &recordingProvider{sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), recorder}
+}
+
+func (p *recordingProvider) Get() trace.Tracer {
+	return p.Tracer("test")
+}
+
+func (p *recordingProvider) Close() {}
+
+func (p *recordingProvider) spans(name string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range p.recorder.Ended() {
+		if span.Name() == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func Test_Hedged(t *testing.T) {
+	var calls int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		_, _ = w.Write([]byte(r.URL.Query().Get("user")))
+	}))
+	defer s.Close()
+
+	provider := newRecordingProvider()
+	api := NewNamed("v1", WithTracingProvider(provider))
+	api.Add("GetUser", strings.TrimPrefix(s.URL, "http://"))
+
+	start := time.Now()
+	req, _ := request.NewRequest(context.Background(), "/users", nil, request.WithQueryParams(map[string]string{"user": "hedged"}))
+	b, err := api.Hedged("GetUser", http.MethodGet, req, 20*time.Millisecond, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "hedged", string(b))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+
+	assert.Eventually(t, func() bool {
+		return len(provider.spans("GetUser")) == 2
+	}, time.Second, 10*time.Millisecond)
+	parent := provider.spans("hedged GetUser")[0]
+	for _, span := range provider.spans("GetUser") {
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+}
+
+func Test_Hedged_Failures(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("GetUser", "localhost:9999")
+	get := api.Expect("GetUser").Respond(http.StatusInternalServerError, nil).Respond(http.StatusOK, []byte("ok"))
+
+	// failed attempt is retried right away, posts are hedged with an idempotency key
+	req, _ := request.NewRequest(context.Background(), "/users", strings.NewReader("body"),
+		request.WithHeaders(map[string][]string{"Idempotency-Key": {"1"}}))
+	b, err := api.Hedged("GetUser", http.MethodPost, req, time.Minute, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(b))
+	assert.Equal(t, 2, get.Calls())
+
+	api.ResetHooks()
+	get = api.Expect("GetUser").Respond(http.StatusInternalServerError, nil)
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Hedged("GetUser", http.MethodGet, req, time.Millisecond, 2)
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, 2, get.Calls())
+
+	// posts without a key are sent once
+	api.ResetHooks()
+	get = api.Expect("GetUser").Respond(http.StatusInternalServerError, nil)
+	req, _ = request.NewRequest(context.Background(), "/users", strings.NewReader("body"))
+	_, err = api.Hedged("GetUser", http.MethodPost, req, time.Millisecond, 3)
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, 1, get.Calls())
+
+	// client errors are returned
+	api.ResetHooks()
+	get = api.Expect("GetUser").Respond(http.StatusNotFound, nil)
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Hedged("GetUser", http.MethodGet, req, time.Minute, 3)
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	assert.Equal(t, 1, get.Calls())
+}
+
+func Test_FanOut(t *testing.T) {
+	provider := newRecordingProvider()
+	api := NewNamed("v1", WithTracingProvider(provider))
+	api.Add("GetUser", "localhost:9999")
+	api.Add("GetAdmin", "localhost:9999")
+	api.Add("GetGuest", "localhost:9999")
+	api.Expect("GetUser").RespondJSON(http.StatusOK, map[string]string{"Name": "user"})
+	api.Expect("GetAdmin").RespondJSON(http.StatusOK, map[string]string{"Name": "admin"})
+	api.Expect("GetGuest").Respond(http.StatusNotFound, nil)
+
+	var calls []Call
+	for _, apiName := range []string{"GetUser", "GetAdmin", "GetGuest"} {
+		req, _ := request.NewRequest(context.Background(), "/users", nil)
+		calls = append(calls, Call{ApiName: apiName, Method: http.MethodGet, Request: req})
+	}
+
+	results := FanOut[struct{ Name string }](context.Background(), api, calls...)
+	assert.Len(t, results, 3)
+	assert.Equal(t, "user", results[0].Value.Name)
+	assert.Equal(t, "admin", results[1].Value.Name)
+	assert.Error(t, results[2].Err)
+	assert.Equal(t, "GetGuest", results[2].ApiName)
+
+	parent := provider.spans("fan-out")[0]
+	for _, apiName := range []string{"GetUser", "GetAdmin", "GetGuest"} {
+		assert.Equal(t, parent.SpanContext().SpanID(), provider.spans(apiName)[0].Parent().SpanID())
+	}
+}
@@ -0,0 +1,78 @@
+package internalApi
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RoundTripFunc sends the request and returns its response
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Interceptor wraps the next RoundTripFunc in the chain. It can inspect or modify the
+// outgoing request before calling next, and the response (or error) returned by next.
+//
+// Requests pass through the interceptors in this order, before being sent over http or to
+// the apiName's hook:
+//   - the built-in interceptors (api and default headers, content-type)
+//   - the global interceptors (WithInterceptors)
+//   - the apiName's interceptors (WithApiInterceptors)
+//   - schema validation (WithRequestSchema, WithResponseSchema, WithOpenAPI)
+//   - the cache (WithCache, WithApiCache)
+//   - retries (WithRetry)
+//   - the apiName's then the host's rate limits (WithRateLimit, WithHostRateLimit)
+//   - metrics (WithMetrics)
+//   - injected faults (InjectFault)
+//   - the cassette recorder (WithRecording), then the HAR recorder (WithHAR)
+//   - compression (WithCompression)
+//   - logging
+type Interceptor func(next RoundTripFunc) RoundTripFunc
+
+// chain the interceptors around rt, the first interceptor sees the request first
+func chain(rt RoundTripFunc, interceptors ...Interceptor) RoundTripFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		rt = interceptors[i](rt)
+	}
+	return rt
+}
+
+// withHeaders sets headers not already present on the request
+func withHeaders(headers map[string]string, traced bool) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			span := trace.SpanFromContext(req.Context())
+			for key, val := range headers {
+				if req.Header.Get(key) == "" {
+					req.Header.Set(key, val)
+					if traced {
+						span.SetAttributes(attribute.Key("internal-header" + key).String(val))
+					}
+				}
+			}
+			return next(req)
+		}
+	}
+}
+
+// withContentType defaults to json for post,put,patch, unless set by the request, eg. by
+// request.WithForm
+func withContentType(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.Method, "P") && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return next(req)
+	}
+}
+
+// withLogging logs the request as it is sent
+func withLogging(logger Logger) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			withTrace(req.Context(), logger).Debug("sending request", "url", req.URL.String(), "method", req.Method)
+			return next(req)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package internalApi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Interceptors(t *testing.T) {
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next(req)
+			}
+		}
+	}
+	bearer := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("Authorization", "Bearer token")
+			rsp, err := next(req)
+			if err == nil {
+				rsp.Header.Set("X-Intercepted", "true")
+			}
+			return rsp, err
+		}
+	}
+
+	api := NewNamed("v1", WithInterceptors(trace("global"), bearer))
+	api.Add("GetUser", "localhost:9999", WithApiInterceptors(trace("api")))
+	api.Add("AddUser", "localhost:9999")
+
+	var auth, contentType string
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	})
+	api.ExpectHook("AddUser", func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Get("GetUser", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer token", auth)
+	assert.Equal(t, []string{"global", "api"}, order)
+
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Post("AddUser", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, []string{"global", "api", "global"}, order)
+}
@@ -0,0 +1,122 @@
+package internalApi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-hclog"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+Logging
+- named apis log through a Logger, logrus' standard logger by default, see WithLogger
+- adapters for logrus, hclog and log/slog (NewSlogLogger needs go1.21)
+- log lines of traced requests carry the trace_id and span_id
+*/
+
+// Logger logs a message with key value pairs, eg. Debug("sending request", "url", url).
+// hclog.Logger and *slog.Logger implement it
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// WithLogger logs through the logger instead of logrus' standard logger
+func WithLogger(logger Logger) Option {
+	return func(api *NamedApi) {
+		api.logger = logger
+	}
+}
+
+// NewLogrusLogger adapts a logrus logger, eg. logrus.StandardLogger() or an *Entry with fields
+func NewLogrusLogger(logger log.FieldLogger) Logger {
+	return &logrusLogger{logger}
+}
+
+// NewHclogLogger adapts a hclog logger, eg. mLogger.Get(name)
+func NewHclogLogger(logger hclog.Logger) Logger {
+	return logger
+}
+
+// NewNopLogger discards every log line
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+type logrusLogger struct {
+	logger log.FieldLogger
+}
+
+func (l *logrusLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger.WithFields(logrusFields(keyvals)).Debug(msg)
+}
+
+func (l *logrusLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger.WithFields(logrusFields(keyvals)).Info(msg)
+}
+
+func (l *logrusLogger) Warn(msg string, keyvals ...interface{}) {
+	l.logger.WithFields(logrusFields(keyvals)).Warn(msg)
+}
+
+func (l *logrusLogger) Error(msg string, keyvals ...interface{}) {
+	l.logger.WithFields(logrusFields(keyvals)).Error(msg)
+}
+
+// key value pairs as fields, an odd value is kept as EXTRA_VALUE_AT_END like hclog
+func logrusFields(keyvals []interface{}) log.Fields {
+	fields := log.Fields{}
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			fields["EXTRA_VALUE_AT_END"] = keyvals[i]
+			break
+		}
+		fields[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+	return fields
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// withTrace adds the trace_id and span_id of the span in ctx to every log line
+func withTrace(ctx context.Context, logger Logger) Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return logger
+	}
+	return &traceLogger{logger: logger, keyvals: []interface{}{"trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String()}}
+}
+
+type traceLogger struct {
+	logger  Logger
+	keyvals []interface{}
+}
+
+func (l *traceLogger) with(keyvals []interface{}) []interface{} {
+	return append(append([]interface{}{}, keyvals...), l.keyvals...)
+}
+
+func (l *traceLogger) Debug(msg string, keyvals ...interface{}) {
+	l.logger.Debug(msg, l.with(keyvals)...)
+}
+
+func (l *traceLogger) Info(msg string, keyvals ...interface{}) {
+	l.logger.Info(msg, l.with(keyvals)...)
+}
+
+func (l *traceLogger) Warn(msg string, keyvals ...interface{}) {
+	l.logger.Warn(msg, l.with(keyvals)...)
+}
+
+func (l *traceLogger) Error(msg string, keyvals ...interface{}) {
+	l.logger.Error(msg, l.with(keyvals)...)
+}
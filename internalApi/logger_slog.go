@@ -0,0 +1,14 @@
+//go:build go1.21
+
+package internalApi
+
+import "log/slog"
+
+// NewSlogLogger adapts a slog logger, slog.Default() if nil. log/slog was added in go1.21,
+// NewSlogLogger is only built with go1.21 or later (//go:build go1.21)
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger
+}
@@ -0,0 +1,33 @@
+//go:build go1.21
+
+package internalApi
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	provider := newRecordingProvider()
+	api := NewNamed("v1", WithLogger(NewSlogLogger(logger)), WithTracingProvider(provider))
+	api.Add("GetUser", "localhost:9999")
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Call("GetUser", req)
+	assert.NoError(t, err)
+
+	traceId := provider.spans("GetUser")[0].SpanContext().TraceID().String()
+	assert.Contains(t, buf.String(), `msg="sending request"`)
+	assert.Contains(t, buf.String(), "trace_id="+traceId)
+}
@@ -0,0 +1,66 @@
+package internalApi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/hashicorp/go-hclog"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LogrusLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&log.JSONFormatter{})
+	logger.SetLevel(log.DebugLevel)
+
+	provider := newRecordingProvider()
+	api := NewNamed("v1", WithLogger(NewLogrusLogger(logger)), WithTracingProvider(provider))
+	api.Add("GetUser", "localhost:9999")
+	api.ExpectHook("GetUser", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Call("GetUser", req)
+	assert.NoError(t, err)
+
+	spans := provider.spans("GetUser")
+	assert.Len(t, spans, 1)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.NotEmpty(t, lines)
+	for _, line := range lines {
+		var fields map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &fields))
+		assert.Equal(t, spans[0].SpanContext().TraceID().String(), fields["trace_id"], line)
+		assert.Equal(t, "http://localhost:9999/v1/users", fields["url"], line)
+	}
+}
+
+func Test_HclogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := hclog.New(&hclog.LoggerOptions{Output: &buf, Level: hclog.Debug, JSONFormat: true})
+
+	api := NewNamed("v1", WithLogger(NewHclogLogger(logger)))
+	api.Add("GetUser", "localhost:1") // refused
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Call("GetUser", req)
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), `"@message":"sending request"`)
+	assert.Contains(t, buf.String(), `"@level":"error"`)
+	// fixed messages, the error as a field
+	assert.Contains(t, buf.String(), `"@message":"error sending request"`)
+	assert.Contains(t, buf.String(), `"error":`)
+	// untraced
+	assert.NotContains(t, buf.String(), "trace_id")
+}
+
+func Test_logrusFields(t *testing.T) {
+	assert.Equal(t, log.Fields{"a": 1, "EXTRA_VALUE_AT_END": "b"}, logrusFields([]interface{}{"a", 1, "b"}))
+}
@@ -0,0 +1,329 @@
+package internalApi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+)
+
+// MetricLabels identify the measurements of a request. Status is 0 for requests in flight
+// and for requests that failed without a response
+type MetricLabels struct {
+	ApiName string
+	Host    string
+	Method  string
+	Status  int
+}
+
+// Metrics records outbound requests, see WithMetrics
+type Metrics interface {
+	// Started is called before the request is sent
+	Started(ctx context.Context, labels MetricLabels)
+	// Done is called once the response body is closed, or when the request fails
+	Done(ctx context.Context, labels MetricLabels, latency time.Duration, responseSize int64)
+}
+
+// interceptor measuring requests for apiName
+func withMetrics(metrics Metrics, apiName string) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			labels := MetricLabels{ApiName: apiName, Host: req.URL.Host, Method: req.Method}
+			start := time.Now()
+			metrics.Started(req.Context(), labels)
+
+			resp, err := next(req)
+			if err != nil {
+				metrics.Done(req.Context(), labels, time.Since(start), 0)
+				return resp, err
+			}
+
+			labels.Status = resp.StatusCode
+			resp.Body = &measuredBody{ReadCloser: resp.Body, done: func(size int64) {
+				metrics.Done(req.Context(), labels, time.Since(start), size)
+			}}
+			return resp, nil
+		}
+	}
+}
+
+// measuredBody reports the bytes read once closed
+type measuredBody struct {
+	io.ReadCloser
+	size int64
+	done func(size int64)
+	once sync.Once
+}
+
+func (b *measuredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *measuredBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.done(b.size)
+	})
+	return err
+}
+
+// DefaultLatencyBuckets in seconds
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// MetricsRegistry keeps metrics in memory, eg. for tests. It serves them in the Prometheus
+// text format as an http.Handler
+type MetricsRegistry struct {
+	buckets []float64
+
+	lock     sync.Mutex
+	series   map[MetricLabels]*metricSeries
+	inFlight map[MetricLabels]int64 // labels without status
+}
+
+type metricSeries struct {
+	requests     int64
+	responseSize int64
+	latency      Histogram
+}
+
+// Histogram of latencies in seconds, Counts[i] is the number of requests within Buckets[i]
+type Histogram struct {
+	Buckets []float64
+	Counts  []int64
+	Count   int64
+	Sum     float64
+}
+
+// NewMetricsRegistry with latency buckets in seconds, DefaultLatencyBuckets if none
+func NewMetricsRegistry(buckets ...float64) *MetricsRegistry {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &MetricsRegistry{buckets: buckets, series: map[MetricLabels]*metricSeries{}, inFlight: map[MetricLabels]int64{}}
+}
+
+func (m *MetricsRegistry) Started(_ context.Context, labels MetricLabels) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	labels.Status = 0
+	m.inFlight[labels]++
+}
+
+func (m *MetricsRegistry) Done(_ context.Context, labels MetricLabels, latency time.Duration, responseSize int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	status := labels.Status
+	labels.Status = 0
+	m.inFlight[labels]--
+	labels.Status = status
+
+	s := m.series[labels]
+	if s == nil {
+		s = &metricSeries{latency: Histogram{Buckets: m.buckets, Counts: make([]int64, len(m.buckets))}}
+		m.series[labels] = s
+	}
+	s.requests++
+	s.responseSize += responseSize
+	seconds := latency.Seconds()
+	for i, bucket := range m.buckets {
+		if seconds <= bucket {
+			s.latency.Counts[i]++
+		}
+	}
+	s.latency.Count++
+	s.latency.Sum += seconds
+}
+
+// Requests returns the number of requests for apiName with status, for any method and host
+func (m *MetricsRegistry) Requests(apiName string, status int) int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var n int64
+	for labels, s := range m.series {
+		if labels.ApiName == apiName && labels.Status == status {
+			n += s.requests
+		}
+	}
+	return n
+}
+
+// InFlight returns the number of requests in flight for apiName
+func (m *MetricsRegistry) InFlight(apiName string) int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var n int64
+	for labels, inFlight := range m.inFlight {
+		if labels.ApiName == apiName {
+			n += inFlight
+		}
+	}
+	return n
+}
+
+// ResponseSize returns the response bytes read for apiName
+func (m *MetricsRegistry) ResponseSize(apiName string) int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var n int64
+	for labels, s := range m.series {
+		if labels.ApiName == apiName {
+			n += s.responseSize
+		}
+	}
+	return n
+}
+
+// Latency returns the latency histogram for apiName, for any status, method and host
+func (m *MetricsRegistry) Latency(apiName string) Histogram {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	h := Histogram{Buckets: m.buckets, Counts: make([]int64, len(m.buckets))}
+	for labels, s := range m.series {
+		if labels.ApiName != apiName {
+			continue
+		}
+		for i := range h.Counts {
+			h.Counts[i] += s.latency.Counts[i]
+		}
+		h.Count += s.latency.Count
+		h.Sum += s.latency.Sum
+	}
+	return h
+}
+
+func (l MetricLabels) prometheus(extra ...string) string {
+	labels := []string{
+		fmt.Sprintf("api=%q", l.ApiName),
+		fmt.Sprintf("host=%q", l.Host),
+		fmt.Sprintf("method=%q", l.Method),
+	}
+	if l.Status != 0 {
+		labels = append(labels, fmt.Sprintf("status=%q", strconv.Itoa(l.Status)))
+	}
+	return "{" + strings.Join(append(labels, extra...), ",") + "}"
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format
+func (m *MetricsRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	var series []MetricLabels
+	for labels := range m.series {
+		series = append(series, labels)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].prometheus() < series[j].prometheus()
+	})
+
+	fmt.Fprintln(w, "# HELP internalapi_requests_total Outbound requests.")
+	fmt.Fprintln(w, "# TYPE internalapi_requests_total counter")
+	for _, labels := range series {
+		fmt.Fprintf(w, "internalapi_requests_total%s %d\n", labels.prometheus(), m.series[labels].requests)
+	}
+
+	fmt.Fprintln(w, "# HELP internalapi_request_duration_seconds Outbound request latency.")
+	fmt.Fprintln(w, "# TYPE internalapi_request_duration_seconds histogram")
+	for _, labels := range series {
+		h := m.series[labels].latency
+		for i, bucket := range h.Buckets {
+			fmt.Fprintf(w, "internalapi_request_duration_seconds_bucket%s %d\n", labels.prometheus(fmt.Sprintf("le=%q", strconv.FormatFloat(bucket, 'g', -1, 64))), h.Counts[i])
+		}
+		fmt.Fprintf(w, "internalapi_request_duration_seconds_bucket%s %d\n", labels.prometheus(`le="+Inf"`), h.Count)
+		fmt.Fprintf(w, "internalapi_request_duration_seconds_sum%s %g\n", labels.prometheus(), h.Sum)
+		fmt.Fprintf(w, "internalapi_request_duration_seconds_count%s %d\n", labels.prometheus(), h.Count)
+	}
+
+	fmt.Fprintln(w, "# HELP internalapi_response_size_bytes_total Outbound response bytes read.")
+	fmt.Fprintln(w, "# TYPE internalapi_response_size_bytes_total counter")
+	for _, labels := range series {
+		fmt.Fprintf(w, "internalapi_response_size_bytes_total%s %d\n", labels.prometheus(), m.series[labels].responseSize)
+	}
+
+	var inFlight []MetricLabels
+	for labels := range m.inFlight {
+		inFlight = append(inFlight, labels)
+	}
+	sort.Slice(inFlight, func(i, j int) bool {
+		return inFlight[i].prometheus() < inFlight[j].prometheus()
+	})
+	fmt.Fprintln(w, "# HELP internalapi_requests_in_flight Outbound requests in flight.")
+	fmt.Fprintln(w, "# TYPE internalapi_requests_in_flight gauge")
+	for _, labels := range inFlight {
+		fmt.Fprintf(w, "internalapi_requests_in_flight%s %d\n", labels.prometheus(), m.inFlight[labels])
+	}
+}
+
+// otelMetrics records to OpenTelemetry instruments
+type otelMetrics struct {
+	requests     syncint64.Counter
+	inFlight     syncint64.UpDownCounter
+	latency      syncfloat64.Histogram
+	responseSize syncint64.Histogram
+}
+
+// NewOtelMetrics records metrics with the OpenTelemetry meter
+func NewOtelMetrics(meter metric.Meter) (Metrics, error) {
+	var m otelMetrics
+	var err error
+	if m.requests, err = meter.SyncInt64().Counter("internalapi.requests", instrument.WithDescription("Outbound requests")); err != nil {
+		return nil, err
+	}
+	if m.inFlight, err = meter.SyncInt64().UpDownCounter("internalapi.requests.in_flight", instrument.WithDescription("Outbound requests in flight")); err != nil {
+		return nil, err
+	}
+	if m.latency, err = meter.SyncFloat64().Histogram("internalapi.request.duration", instrument.WithDescription("Outbound request latency"), instrument.WithUnit(unit.Milliseconds)); err != nil {
+		return nil, err
+	}
+	if m.responseSize, err = meter.SyncInt64().Histogram("internalapi.response.size", instrument.WithDescription("Outbound response size"), instrument.WithUnit(unit.Bytes)); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (l MetricLabels) attributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("api", l.ApiName),
+		attribute.String("host", l.Host),
+		attribute.String("method", l.Method),
+	}
+	if l.Status != 0 {
+		attrs = append(attrs, attribute.Int("status", l.Status))
+	}
+	return attrs
+}
+
+func (m *otelMetrics) Started(ctx context.Context, labels MetricLabels) {
+	m.inFlight.Add(ctx, 1, labels.attributes()...)
+}
+
+func (m *otelMetrics) Done(ctx context.Context, labels MetricLabels, latency time.Duration, responseSize int64) {
+	status := labels.Status
+	labels.Status = 0
+	m.inFlight.Add(ctx, -1, labels.attributes()...)
+	labels.Status = status
+
+	attrs := labels.attributes()
+	m.requests.Add(ctx, 1, attrs...)
+	m.latency.Record(ctx, float64(latency)/float64(time.Millisecond), attrs...)
+	m.responseSize.Record(ctx, responseSize, attrs...)
+}
@@ -0,0 +1,65 @@
+package internalApi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/metric/nonrecording"
+)
+
+func Test_Metrics(t *testing.T) {
+	registry := NewMetricsRegistry()
+	api := NewNamed("v1", WithMetrics(registry))
+	api.Add("GetUser", "localhost:9999")
+	api.Add("AddUser", "localhost:9999")
+	api.Expect("GetUser").Respond(http.StatusOK, []byte("user"))
+	api.Expect("AddUser").Respond(http.StatusBadRequest, nil)
+
+	for i := 0; i < 3; i++ {
+		req, _ := request.NewRequest(context.Background(), "/users", nil)
+		_, err := api.Get("GetUser", req)
+		assert.NoError(t, err)
+	}
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Post("AddUser", req)
+	assert.Error(t, err)
+
+	assert.Equal(t, int64(3), registry.Requests("GetUser", http.StatusOK))
+	assert.Equal(t, int64(1), registry.Requests("AddUser", http.StatusBadRequest))
+	assert.Equal(t, int64(12), registry.ResponseSize("GetUser"))
+	assert.Equal(t, int64(0), registry.InFlight("GetUser"))
+	assert.Equal(t, int64(3), registry.Latency("GetUser").Count)
+
+	// stream is in flight until closed
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	rsp, err := api.Stream("GetUser", http.MethodGet, req)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), registry.InFlight("GetUser"))
+	assert.NoError(t, rsp.Body.Close())
+	assert.Equal(t, int64(0), registry.InFlight("GetUser"))
+
+	rr := httptest.NewRecorder()
+	registry.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	assert.Contains(t, body, `internalapi_requests_total{api="GetUser",host="localhost:9999",method="GET",status="200"} 4`)
+	assert.Contains(t, body, `internalapi_request_duration_seconds_count{api="AddUser",host="localhost:9999",method="POST",status="400"} 1`)
+	assert.Contains(t, body, `internalapi_requests_in_flight{api="GetUser",host="localhost:9999",method="GET"} 0`)
+	assert.True(t, strings.HasPrefix(body, "# HELP"))
+}
+
+func Test_OtelMetrics(t *testing.T) {
+	metrics, err := NewOtelMetrics(nonrecording.NewNoopMeter())
+	assert.NoError(t, err)
+
+	api := NewNamed("v1", WithMetrics(metrics))
+	api.Add("GetUser", "localhost:9999")
+	api.Expect("GetUser")
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Get("GetUser", req)
+	assert.NoError(t, err)
+}
@@ -2,6 +2,8 @@ package internalApi
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/Ishan27g/go-utils/tracing"
@@ -13,16 +15,72 @@ type Option func(*NamedApi)
 func WithDefaultHttpClient() Option {
 	return func(api *NamedApi) {
 		api.httpClient = &http.Client{Timeout: 1 * time.Minute}
+		api.tracingTransport = false
 	}
 }
 func WithHttpClient(httpClient http.Client) Option {
 	return func(api *NamedApi) {
 		api.httpClient = &httpClient
+		api.tracingTransport = false
 	}
 }
 func WithTracingHttpClient() Option {
 	return func(api *NamedApi) {
-		api.httpClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: 1 * time.Minute}
+		api.httpClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport, otelhttp.WithSpanNameFormatter(spanName)), Timeout: 1 * time.Minute}
+		api.tracingTransport = true
+	}
+}
+
+// WithBasePath sets the path prefixed to every endpoint instead of the version
+func WithBasePath(path string) Option {
+	return func(api *NamedApi) {
+		api.basePath = basePath(path)
+	}
+}
+
+// WithInterceptors adds interceptors for requests to every apiName
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(api *NamedApi) {
+		api.interceptors = append(api.interceptors, interceptors...)
+	}
+}
+
+// WithCache serves repeated GET requests from the cache, honoring Cache-Control,
+// ETag and Last-Modified response headers
+func WithCache(cache Cache) Option {
+	return func(api *NamedApi) {
+		api.cache = cache
+	}
+}
+
+// WithMaxBodySize fails requests whose response body exceeds maxBodySize bytes with
+// ErrBodyTooLarge. Does not apply to NamedApi.Stream
+func WithMaxBodySize(maxBodySize int64) Option {
+	return func(api *NamedApi) {
+		api.maxBodySize = maxBodySize
+	}
+}
+
+// WithRecording records every request and response to a cassette per apiName in dir,
+// written on NamedApi.Close. Bodies over the max body size are truncated, see NamedApi.Replay
+func WithRecording(dir string) Option {
+	return func(api *NamedApi) {
+		api.recorder = newRecorder(dir)
+	}
+}
+
+// WithHAR records every exchange, with timings, to the HARRecorder, see HARRecorder.WriteFile
+func WithHAR(recorder *HARRecorder) Option {
+	return func(api *NamedApi) {
+		api.har = recorder
+	}
+}
+
+// WithMetrics records the count, latency, response size and in-flight requests per
+// apiName, host and status. See NewMetricsRegistry and NewOtelMetrics
+func WithMetrics(metrics Metrics) Option {
+	return func(api *NamedApi) {
+		api.metrics = metrics
 	}
 }
 func WithTracingProvider(provider tracing.TraceProvider) Option {
@@ -30,3 +88,133 @@ func WithTracingProvider(provider tracing.TraceProvider) Option {
 		api.tracingProvider = provider
 	}
 }
+
+// apiConfig holds the optional settings registered for an apiName
+type apiConfig struct {
+	method  string
+	path    string
+	params  []string // {params} in path
+	headers map[string]string
+	timeout time.Duration
+	err     error
+
+	grpcMethod string
+
+	interceptors []Interceptor
+	cache        Cache
+	maxBodySize  int64
+	limiter      *tokenBucket
+	retry        *Retry
+	validation   *schemas
+	query        url.Values
+	compression  *Compression
+
+	resolver      Resolver
+	balancer      Balancer
+	ejectFailures int
+	ejectDuration time.Duration
+	pool          *endpointPool
+}
+
+type ApiOption func(*apiConfig)
+
+// WithMethod sets the http method used by NamedApi.Call for this apiName
+func WithMethod(method string) ApiOption {
+	return func(config *apiConfig) {
+		config.method = strings.ToUpper(method)
+	}
+}
+
+// WithPath sets the endpoint used when the request does not provide one. The path can be a
+// template with {params}, eg. /users/{id}, filled in by request.WithPathParams
+func WithPath(path string) ApiOption {
+	return func(config *apiConfig) {
+		if path != "" && !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		config.path = path
+		config.params, config.err = parseRoute(path)
+	}
+}
+
+// WithApiQuery sets default query params, the request's own params take precedence
+func WithApiQuery(query url.Values) ApiOption {
+	return func(config *apiConfig) {
+		config.query = query
+	}
+}
+
+// WithRoute sets the method and path template, eg. "GET /users/{id}"
+func WithRoute(route string) ApiOption {
+	return func(config *apiConfig) {
+		path := route
+		if method, p, ok := strings.Cut(strings.TrimSpace(route), " "); ok {
+			WithMethod(method)(config)
+			path = strings.TrimSpace(p)
+		}
+		WithPath(path)(config)
+	}
+}
+
+// WithApiHeaders sets default headers sent with every request for this apiName
+func WithApiHeaders(headers map[string]string) ApiOption {
+	return func(config *apiConfig) {
+		for key, val := range headers {
+			config.headers[key] = val
+		}
+	}
+}
+
+// WithTimeout sets the timeout for every request to this apiName, can be overridden
+// per call with request.WithTimeout. The http.Client timeout still applies
+func WithTimeout(timeout time.Duration) ApiOption {
+	return func(config *apiConfig) {
+		config.timeout = timeout
+	}
+}
+
+// WithApiInterceptors adds interceptors for requests to this apiName, they run after
+// the global interceptors
+func WithApiInterceptors(interceptors ...Interceptor) ApiOption {
+	return func(config *apiConfig) {
+		config.interceptors = append(config.interceptors, interceptors...)
+	}
+}
+
+// WithApiCache caches responses for this apiName in cache instead of NamedApi's cache
+func WithApiCache(cache Cache) ApiOption {
+	return func(config *apiConfig) {
+		config.cache = cache
+	}
+}
+
+// WithApiMaxBodySize overrides NamedApi's max body size for this apiName
+func WithApiMaxBodySize(maxBodySize int64) ApiOption {
+	return func(config *apiConfig) {
+		config.maxBodySize = maxBodySize
+	}
+}
+
+// WithResolver resolves the endpoints for this apiName on every request, the host
+// passed to NamedApi.Add is then only a logical name
+func WithResolver(resolver Resolver) ApiOption {
+	return func(config *apiConfig) {
+		config.resolver = resolver
+	}
+}
+
+// WithBalancer sets how an endpoint is selected when using WithResolver, defaults to RoundRobin
+func WithBalancer(balancer Balancer) ApiOption {
+	return func(config *apiConfig) {
+		config.balancer = balancer
+	}
+}
+
+// WithEjection ejects an endpoint for duration after consecutive failed requests
+// (connection errors or 5xx responses)
+func WithEjection(failures int, duration time.Duration) ApiOption {
+	return func(config *apiConfig) {
+		config.ejectFailures = failures
+		config.ejectDuration = duration
+	}
+}
@@ -0,0 +1,264 @@
+package internalApi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+/*
+Postman v2.1 collections
+- every request in the collection (including nested folders) is added as a named api
+- the request name is used as the apiName, names used more than once are prefixed by their
+  folders, eg. users/Get
+- saved example responses can be registered as hooks
+*/
+
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []postmanItem     `json:"item"`
+	Variable []postmanKeyValue `json:"variable"`
+}
+
+type postmanItem struct {
+	Name     string            `json:"name"`
+	Item     []postmanItem     `json:"item"` // folder
+	Request  *postmanRequest   `json:"request"`
+	Response []postmanResponse `json:"response"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	Header []postmanKeyValue `json:"header"`
+	Url    postmanUrl        `json:"url"`
+}
+
+type postmanResponse struct {
+	Name   string            `json:"name"`
+	Code   int               `json:"code"`
+	Header []postmanKeyValue `json:"header"`
+	Body   string            `json:"body"`
+}
+
+type postmanKeyValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+// postmanUrl is either a raw string or an object
+type postmanUrl struct {
+	Raw  string   `json:"raw"`
+	Host []string `json:"host"`
+	Port string   `json:"port"`
+	Path []string `json:"path"`
+}
+
+func (u *postmanUrl) UnmarshalJSON(b []byte) error {
+	var raw string
+	if err := json.Unmarshal(b, &raw); err == nil {
+		u.Raw = raw
+		return nil
+	}
+	type plain postmanUrl
+	return json.Unmarshal(b, (*plain)(u))
+}
+
+type postmanLoader struct {
+	host      string
+	examples  bool
+	variables map[string]string
+}
+
+type PostmanOption func(*postmanLoader)
+
+// WithPostmanHost overrides the host of every request in the collection
+func WithPostmanHost(host string) PostmanOption {
+	return func(l *postmanLoader) {
+		l.host = host
+	}
+}
+
+// WithPostmanExamples registers the first saved example response of each request as its hook
+func WithPostmanExamples() PostmanOption {
+	return func(l *postmanLoader) {
+		l.examples = true
+	}
+}
+
+// WithPostmanVariables sets {{variables}}, overriding the ones defined in the collection
+func WithPostmanVariables(variables map[string]string) PostmanOption {
+	return func(l *postmanLoader) {
+		for key, val := range variables {
+			l.variables[key] = val
+		}
+	}
+}
+
+// LoadPostmanFile reads a Postman v2.1 collection from a file, see LoadPostman
+func (na *NamedApi) LoadPostmanFile(path string, options ...PostmanOption) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return na.LoadPostman(f, options...)
+}
+
+// LoadPostman adds every request of a Postman v2.1 collection as a named api and
+// returns the added apiNames
+func (na *NamedApi) LoadPostman(r io.Reader, options ...PostmanOption) ([]string, error) {
+	var collection postmanCollection
+	if err := json.NewDecoder(r).Decode(&collection); err != nil {
+		return nil, fmt.Errorf("invalid postman collection : %v", err)
+	}
+	if collection.Info.Schema != "" && !strings.Contains(collection.Info.Schema, "v2.1") {
+		return nil, errors.New("unsupported postman collection schema " + collection.Info.Schema)
+	}
+
+	loader := &postmanLoader{variables: map[string]string{}}
+	for _, v := range collection.Variable {
+		loader.variables[v.Key] = v.Value
+	}
+	for _, option := range options {
+		option(loader)
+	}
+
+	// requests by their folders
+	var requests []postmanItem
+	var folders [][]string
+	names := map[string]int{}
+	var walk func(items []postmanItem, folder []string)
+	walk = func(items []postmanItem, folder []string) {
+		for _, item := range items {
+			if item.Request == nil {
+				walk(item.Item, append(append([]string{}, folder...), item.Name))
+				continue
+			}
+			requests, folders = append(requests, item), append(folders, folder)
+			names[item.Name]++
+		}
+	}
+	walk(collection.Item, nil)
+
+	var added []string
+	seen := map[string]bool{}
+	for i, item := range requests {
+		apiName := item.Name
+		if names[apiName] > 1 {
+			apiName = strings.Join(append(folders[i], item.Name), "/")
+		}
+		if seen[apiName] {
+			return added, fmt.Errorf("postman request %s : duplicate name", apiName)
+		}
+		seen[apiName] = true
+		if err := na.addPostmanItem(loader, apiName, item); err != nil {
+			return added, err
+		}
+		added = append(added, apiName)
+	}
+	return added, nil
+}
+
+func (na *NamedApi) addPostmanItem(loader *postmanLoader, apiName string, item postmanItem) error {
+	u, err := loader.url(item.Request.Url)
+	if err != nil {
+		return fmt.Errorf("postman request %s : %v", apiName, err)
+	}
+
+	host := u.Scheme + "://" + u.Host
+	if loader.host != "" {
+		host = loader.host
+	}
+	if u.Host == "" && loader.host == "" {
+		return fmt.Errorf("postman request %s : missing host", apiName)
+	}
+
+	// the base path is added by NamedApi
+	path := u.Path
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if base := na.basePathFor(host); base != "" && (path == base || strings.HasPrefix(path, base+"/")) {
+		path = strings.TrimPrefix(path, base)
+	}
+
+	headers := map[string]string{}
+	for _, h := range item.Request.Header {
+		if !h.Disabled {
+			headers[h.Key] = loader.resolve(h.Value)
+		}
+	}
+
+	method := item.Request.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	options := []ApiOption{WithMethod(method), WithPath(path), WithApiHeaders(headers)}
+	if u.RawQuery != "" {
+		options = append(options, WithApiQuery(u.Query()))
+	}
+	na.Add(apiName, host, options...)
+
+	if loader.examples && len(item.Response) > 0 {
+		if err := na.setHook(apiName, postmanHook(item.Response[0])); err != nil {
+			return fmt.Errorf("postman request %s : %v", apiName, err)
+		}
+	}
+	return nil
+}
+
+// hook that replies with a saved example response
+func postmanHook(example postmanResponse) HttpHook {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range example.Header {
+			if !h.Disabled {
+				w.Header().Add(h.Key, h.Value)
+			}
+		}
+		code := example.Code
+		if code == 0 {
+			code = http.StatusOK
+		}
+		w.WriteHeader(code)
+		_, _ = io.WriteString(w, example.Body)
+	}
+}
+
+var postmanVariable = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// replace {{variables}}, unknown variables are left as is
+func (l *postmanLoader) resolve(s string) string {
+	return postmanVariable.ReplaceAllStringFunc(s, func(m string) string {
+		if val, ok := l.variables[postmanVariable.FindStringSubmatch(m)[1]]; ok {
+			return val
+		}
+		return m
+	})
+}
+
+func (l *postmanLoader) url(pu postmanUrl) (*url.URL, error) {
+	raw := pu.Raw
+	if raw == "" {
+		raw = strings.Join(pu.Host, ".")
+		if pu.Port != "" {
+			raw += ":" + pu.Port
+		}
+		raw += "/" + strings.Join(pu.Path, "/")
+	}
+	raw = l.resolve(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	return url.Parse(raw)
+}
@@ -0,0 +1,149 @@
+package internalApi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+const collection = `{
+  "info": {
+    "name": "users",
+    "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+  },
+  "variable": [{"key": "baseUrl", "value": "http://localhost:9999"}],
+  "item": [
+    {
+      "name": "users",
+      "item": [
+        {
+          "name": "GetUser",
+          "request": {
+            "method": "GET",
+            "header": [{"key": "Accept", "value": "application/json"}],
+            "url": {"raw": "{{baseUrl}}/v1/users", "host": ["{{baseUrl}}"], "path": ["v1", "users"]}
+          },
+          "response": [
+            {"name": "ok", "code": 200, "header": [{"key": "Content-Type", "value": "application/json"}], "body": "{\"Name\":\"postman-user\"}"}
+          ]
+        }
+      ]
+    },
+    {
+      "name": "AddUser",
+      "request": {"method": "POST", "url": "{{baseUrl}}/v1/users"},
+      "response": [{"name": "created", "code": 201, "body": ""}]
+    }
+  ]
+}`
+
+func Test_Postman(t *testing.T) {
+	api := NewNamed("v1")
+	added, err := api.LoadPostman(strings.NewReader(collection), WithPostmanExamples())
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"GetUser", "AddUser"}, added)
+
+	assert.Equal(t, "localhost:9999", api.hosts.getHost("GetUser"))
+	assert.Equal(t, http.MethodGet, api.apis["GetUser"].method)
+	assert.Equal(t, "/users", api.apis["GetUser"].path)
+	assert.Equal(t, "application/json", api.apis["GetUser"].headers["Accept"])
+	assert.Equal(t, http.MethodPost, api.apis["AddUser"].method)
+
+	req, _ := request.NewRequest(context.Background(), "", nil)
+	b, err := api.Call("GetUser", req)
+	assert.NoError(t, err)
+
+	var user = struct{ Name string }{}
+	assert.NoError(t, json.Unmarshal(b, &user))
+	assert.Equal(t, "postman-user", user.Name)
+
+	req, _ = request.NewRequest(context.Background(), "", strings.NewReader(`{"Name":"x"}`))
+	_, err = api.Call("AddUser", req)
+	assert.NoError(t, err)
+}
+
+func Test_Postman_Host(t *testing.T) {
+	api := NewNamed("v1")
+	_, err := api.LoadPostman(strings.NewReader(collection), WithPostmanHost("localhost:8080"))
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:8080", api.hosts.getHost("AddUser"))
+	assert.Nil(t, api.apiClient["localhost:8080"].hooks["AddUser"])
+
+	_, err = NewNamed("v1").LoadPostman(strings.NewReader(`{"info":{"schema":"v1.0.0"}}`))
+	assert.Error(t, err)
+}
+
+func Test_Postman_BasePath(t *testing.T) {
+	collection := `{
+  "item": [
+    {"name": "GetUser", "request": {"method": "GET", "url": "http://localhost:9999/api/v1/users"}},
+    {"name": "GetOrder", "request": {"method": "GET", "url": "http://localhost:8888/orders/v2/orders"}}
+  ]
+}`
+	api := NewNamed("v1", WithBasePath("api/v1"))
+	assert.NoError(t, api.AddHost("localhost:8888", WithHostBasePath("orders/v2")))
+	_, err := api.LoadPostman(strings.NewReader(collection))
+	assert.NoError(t, err)
+	assert.Equal(t, "/users", api.apis["GetUser"].path)
+	assert.Equal(t, "/orders", api.apis["GetOrder"].path)
+
+	var paths []string
+	for _, apiName := range []string{"GetUser", "GetOrder"} {
+		api.ExpectHook(apiName, func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		})
+		req, _ := request.NewRequest(context.Background(), "", nil)
+		_, err = api.Call(apiName, req)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"/api/v1/users", "/orders/v2/orders"}, paths)
+}
+
+func Test_Postman_Duplicates(t *testing.T) {
+	collection := `{
+  "item": [
+    {"name": "users", "item": [
+      {"name": "Get", "request": {"method": "GET", "url": "http://localhost:9999/v1/users?page=1&size={{size}}"}, "response": [{"code": 200, "body": "users"}]}
+    ]},
+    {"name": "orders", "item": [
+      {"name": "Get", "request": {"method": "GET", "url": "http://localhost:9999/v1/orders"}, "response": [{"code": 200, "body": "orders"}]}
+    ]},
+    {"name": "Health", "request": {"method": "GET", "url": "http://localhost:9999/health"}}
+  ]
+}`
+	api := NewNamed("v1")
+	added, err := api.LoadPostman(strings.NewReader(collection), WithPostmanExamples(), WithPostmanVariables(map[string]string{"size": "10"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users/Get", "orders/Get", "Health"}, added)
+
+	api.ExpectHook("Health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	var query string
+	api.RemoveHook("users/Get")
+	api.ExpectHook("users/Get", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	})
+	req, _ := request.NewRequest(context.Background(), "", nil, request.WithQueryParams(map[string]string{"size": "20"}))
+	_, err = api.Call("users/Get", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "page=1&size=20", query)
+
+	// loading the examples again fails without panicking
+	_, err = api.LoadPostman(strings.NewReader(collection), WithPostmanExamples())
+	assert.Error(t, err)
+
+	// duplicates in the same folder
+	_, err = NewNamed("v1").LoadPostman(strings.NewReader(`{"item": [
+    {"name": "Get", "request": {"url": "http://localhost:9999/a"}},
+    {"name": "Get", "request": {"url": "http://localhost:9999/b"}}
+  ]}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,167 @@
+package internalApi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by FailFast rate limits when no request is allowed
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimit allows Rate requests per second with bursts of up to Burst requests. Rate must
+// be above zero, limits without a rate are rejected.
+// Requests over the limit wait for their turn (or until the request's context is done),
+// unless FailFast is set in which case they fail with ErrRateLimited.
+//
+// Limits adapt to Retry-After and X-RateLimit-Remaining/X-RateLimit-Reset response
+// headers by holding back requests until the host allows them again
+type RateLimit struct {
+	Rate     float64
+	Burst    int
+	FailFast bool
+}
+
+// tokenBucket implements a RateLimit
+type tokenBucket struct {
+	limit RateLimit
+
+	lock         sync.Mutex
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+}
+
+// reserve a token, returning how long to wait before using it. FailFast limits do not
+// reserve a token when they would have to wait
+func (b *tokenBucket) reserve() (time.Duration, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+
+	var wait time.Duration
+	if now.Before(b.blockedUntil) {
+		wait = b.blockedUntil.Sub(now)
+	}
+	if b.tokens < 1 {
+		refill := time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+		if refill > wait {
+			wait = refill
+		}
+	}
+
+	if wait > 0 && b.limit.FailFast {
+		return wait, fmt.Errorf("%w : retry in %s", ErrRateLimited, wait)
+	}
+	b.tokens--
+	return wait, nil
+}
+
+// give back a reserved token that was not used
+func (b *tokenBucket) cancel() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.tokens++
+}
+
+// hold back requests as asked by the response headers
+func (b *tokenBucket) adapt(resp *http.Response) {
+	var until time.Time
+	now := time.Now()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		retry := resp.Header.Get("Retry-After")
+		if seconds, err := strconv.Atoi(retry); err == nil {
+			until = now.Add(time.Duration(seconds) * time.Second)
+		} else if at, err := http.ParseTime(retry); err == nil {
+			until = at
+		}
+	}
+
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil && remaining <= 0 {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			// either a unix timestamp or seconds until the reset
+			at := time.Unix(reset, 0)
+			if reset < 1e9 {
+				at = now.Add(time.Duration(reset) * time.Second)
+			}
+			if at.After(until) {
+				until = at
+			}
+		}
+	}
+
+	if until.IsZero() {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+}
+
+func (b *tokenBucket) interceptor(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		wait, err := b.reserve()
+		if err != nil {
+			return nil, err
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				b.cancel()
+				return nil, fmt.Errorf("waiting for rate limit : %w", req.Context().Err())
+			}
+		}
+		resp, err := next(req)
+		if err == nil {
+			b.adapt(resp)
+		}
+		return resp, err
+	}
+}
+
+// ErrInvalidRateLimit is returned for rate limits without a rate
+var ErrInvalidRateLimit = errors.New("invalid rate limit : rate must be above zero")
+
+// WithRateLimit limits the requests for this apiName
+func WithRateLimit(limit RateLimit) ApiOption {
+	return func(config *apiConfig) {
+		if limit.Rate <= 0 {
+			config.err = ErrInvalidRateLimit
+			return
+		}
+		config.limiter = newTokenBucket(limit)
+	}
+}
+
+// WithHostRateLimit limits the requests to the host, across all its apiNames
+func WithHostRateLimit(limit RateLimit) HostOption {
+	return func(config *hostConfig) {
+		if limit.Rate <= 0 {
+			config.err = ErrInvalidRateLimit
+			return
+		}
+		config.limiter = newTokenBucket(limit)
+	}
+}
@@ -0,0 +1,107 @@
+package internalApi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RateLimit(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("GetUser", "localhost:9999", WithRateLimit(RateLimit{Rate: 50, Burst: 2}))
+	api.Add("AddUser", "localhost:9999", WithRateLimit(RateLimit{Rate: 1, Burst: 1, FailFast: true}))
+	api.Expect("GetUser")
+	api.Expect("AddUser")
+
+	get := func(o ...request.Option) error {
+		req, _ := request.NewRequest(context.Background(), "/users", nil, o...)
+		_, err := api.Get("GetUser", req)
+		return err
+	}
+
+	// burst, then blocks
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, get())
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+	// blocked until the context is done
+	api.Add("Slow", "localhost:9999", WithRateLimit(RateLimit{Rate: 0.1, Burst: 1}))
+	api.Expect("Slow")
+	req, _ := request.NewRequest(context.Background(), "/", nil)
+	_, err := api.Get("Slow", req)
+	assert.NoError(t, err)
+	req, _ = request.NewRequest(context.Background(), "/", nil, request.WithTimeout(10*time.Millisecond))
+	_, err = api.Get("Slow", req)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// fail fast
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Post("AddUser", req)
+	assert.NoError(t, err)
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Post("AddUser", req)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+}
+
+func Test_RateLimit_Invalid(t *testing.T) {
+	api := NewNamed("v1")
+	assert.ErrorIs(t, api.AddHost("localhost:9999", WithHostRateLimit(RateLimit{Burst: 1})), ErrInvalidRateLimit)
+
+	api.Add("GetUser", "localhost:9999", WithRateLimit(RateLimit{Rate: 0, Burst: 1}))
+	api.Expect("GetUser")
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Get("GetUser", req)
+	assert.ErrorContains(t, err, ErrInvalidRateLimit.Error())
+
+	// a rate left out of the config
+	config, err := ParseConfig(strings.NewReader(`
+apis:
+  GetUser: {host: localhost:9999, route: GET /users, rateLimit: {burst: 10}}
+`))
+	assert.NoError(t, err)
+	_, err = NewNamedFromConfig(config)
+	assert.ErrorContains(t, err, ErrInvalidRateLimit.Error())
+}
+
+func Test_RateLimit_Host(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("GetUser", "localhost:9999")
+	api.Add("AddUser", "localhost:9999")
+	assert.NoError(t, api.AddHost("localhost:9999", WithHostRateLimit(RateLimit{Rate: 1, Burst: 1, FailFast: true})))
+	api.Expect("GetUser")
+	api.Expect("AddUser")
+
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Get("GetUser", req)
+	assert.NoError(t, err)
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Post("AddUser", req)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+}
+
+func Test_RateLimit_Adapt(t *testing.T) {
+	bucket := newTokenBucket(RateLimit{Rate: 1000, Burst: 10, FailFast: true})
+	_, err := bucket.reserve()
+	assert.NoError(t, err)
+
+	bucket.adapt(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"2"}}})
+	wait, err := bucket.reserve()
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.Greater(t, wait, time.Second)
+
+	bucket = newTokenBucket(RateLimit{Rate: 1000, Burst: 10, FailFast: true})
+	bucket.adapt(&http.Response{StatusCode: http.StatusOK, Header: http.Header{
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {"1"},
+	}})
+	_, err = bucket.reserve()
+	assert.True(t, errors.Is(err, ErrRateLimited))
+}
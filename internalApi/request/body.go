@@ -0,0 +1,153 @@
+package request
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// WithForm sends the values url-encoded, replacing the body passed to NewRequest
+func WithForm(values url.Values) Option {
+	return func(request *Request) {
+		request.body = strings.NewReader(values.Encode())
+		request.contentType = "application/x-www-form-urlencoded"
+	}
+}
+
+// WithBinary sends the reader's content as is with the content type, replacing the body
+// passed to NewRequest
+func WithBinary(body io.Reader, contentType string) Option {
+	return func(request *Request) {
+		request.body = body
+		request.contentType = contentType
+	}
+}
+
+// Part of a multipart body, see WithMultipart
+type Part struct {
+	field       string
+	filename    string
+	contentType string
+
+	value  string
+	path   string
+	reader io.Reader
+}
+
+// FormField is a plain form value
+func FormField(field, value string) Part {
+	return Part{field: field, value: value}
+}
+
+// FilePart streams the file at path, its content type is detected from the extension
+func FilePart(field, path string) Part {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return Part{field: field, filename: filepath.Base(path), contentType: contentType, path: path}
+}
+
+// ReaderPart streams the reader's content as a file
+func ReaderPart(field, filename, contentType string, r io.Reader) Part {
+	return Part{field: field, filename: filename, contentType: contentType, reader: r}
+}
+
+// WithMultipart sends a multipart/form-data body with the parts, replacing the body passed
+// to NewRequest. The body is streamed as it is sent, files are read from disk then
+func WithMultipart(parts ...Part) Option {
+	return func(request *Request) {
+		body := &multipartBody{parts: parts}
+		body.pr, body.pw = io.Pipe()
+		body.writer = multipart.NewWriter(body.pw)
+		request.body = body
+		request.contentType = body.writer.FormDataContentType()
+	}
+}
+
+// check that the files of the parts can be read before sending
+func checkParts(body io.Reader) error {
+	mb, ok := body.(*multipartBody)
+	if !ok {
+		return nil
+	}
+	for _, part := range mb.parts {
+		if part.path == "" {
+			continue
+		}
+		info, err := os.Stat(part.path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", part.path)
+		}
+	}
+	return nil
+}
+
+// multipartBody writes the parts through a pipe once it is first read
+type multipartBody struct {
+	parts  []Part
+	writer *multipart.Writer
+	pr     *io.PipeReader
+	pw     *io.PipeWriter
+	start  sync.Once
+}
+
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.start.Do(func() {
+		go func() {
+			_ = b.pw.CloseWithError(b.write())
+		}()
+	})
+	return b.pr.Read(p)
+}
+
+// Close stops writing the parts if they have not been read
+func (b *multipartBody) Close() error {
+	return b.pr.Close()
+}
+
+func (b *multipartBody) write() error {
+	for _, part := range b.parts {
+		if part.filename == "" {
+			if err := b.writer.WriteField(part.field, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": part.field, "filename": part.filename}))
+		header.Set("Content-Type", part.contentType)
+		w, err := b.writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		if part.path != "" {
+			f, err := os.Open(part.path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(w, f)
+			_ = f.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := io.Copy(w, part.reader); err != nil {
+			return err
+		}
+	}
+	return b.writer.Close()
+}
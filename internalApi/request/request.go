@@ -1,10 +1,13 @@
 package request
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // Request is wrapper for internal *http.Request
@@ -14,8 +17,19 @@ type Request struct {
 		username string
 		password string
 	}
+	bearer string
+	apiKey *struct {
+		header string
+		key    string
+	}
 	query   map[string]string
+	params  map[string]string
 	headers map[string][]string
+	timeout time.Duration
+
+	body        io.Reader // set by the body options
+	contentType string
+
 	*http.Request
 }
 type Option func(*Request)
@@ -38,6 +52,73 @@ func WithAuthBasic(username, password string) Option {
 		}{username: username, password: password}
 	}
 }
+func WithBearerToken(token string) Option {
+	return func(request *Request) {
+		request.bearer = token
+	}
+}
+
+// WithApiKey sends the key in the header, eg. X-API-Key
+func WithApiKey(header, key string) Option {
+	return func(request *Request) {
+		request.apiKey = &struct {
+			header string
+			key    string
+		}{header: header, key: key}
+	}
+}
+
+// WithPathParams fills in the {params} of the named api's route, values are escaped.
+// The request's urlEndpoint must be empty
+func WithPathParams(params map[string]string) Option {
+	return func(request *Request) {
+		request.params = params
+	}
+}
+
+// PathParams returns the params set by WithPathParams
+func (r *Request) PathParams() map[string]string {
+	return r.params
+}
+
+// WithTimeout overrides the timeout configured for the named api for this call
+func WithTimeout(timeout time.Duration) Option {
+	return func(request *Request) {
+		request.timeout = timeout
+	}
+}
+
+// Timeout returns the timeout set by WithTimeout
+func (r *Request) Timeout() time.Duration {
+	return r.timeout
+}
+
+// Clone returns a copy of the request with the context ctx. The body is read into memory if
+// it cannot be replayed, so that both requests can be sent
+func (r *Request) Clone(ctx context.Context) (*Request, error) {
+	if r.Request.Body != nil && r.Request.Body != http.NoBody && r.Request.GetBody == nil {
+		b, err := ioutil.ReadAll(r.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		_ = r.Request.Body.Close()
+		r.Request.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+		r.Request.Body, _ = r.Request.GetBody()
+	}
+
+	clone := *r
+	clone.Request = r.Request.Clone(ctx)
+	if r.Request.GetBody != nil {
+		body, err := r.Request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Request.Body = body
+	}
+	return &clone, nil
+}
 
 func NewRequest(ctx context.Context, urlEndpoint string, body io.Reader, o ...Option) (*Request, error) {
 	var (
@@ -54,10 +135,20 @@ func NewRequest(ctx context.Context, urlEndpoint string, body io.Reader, o ...Op
 		option(r)
 	}
 
+	if r.body != nil {
+		if err = checkParts(r.body); err != nil {
+			return nil, err
+		}
+		body = r.body
+	}
+
 	r.Request, err = http.NewRequestWithContext(ctx, "", urlEndpoint, body)
 	if err != nil {
 		return nil, err
 	}
+	if r.contentType != "" {
+		r.Header.Set("Content-Type", r.contentType)
+	}
 
 	// optional headers
 	for key, val := range r.headers {
@@ -83,6 +174,16 @@ func NewRequest(ctx context.Context, urlEndpoint string, body io.Reader, o ...Op
 		r.SetBasicAuth(r.user.username, r.user.password)
 	}
 
+	// bearer token
+	if r.bearer != "" {
+		r.Header.Set("Authorization", "Bearer "+r.bearer)
+	}
+
+	// api key
+	if r.apiKey != nil {
+		r.Header.Set(r.apiKey.header, r.apiKey.key)
+	}
+
 	return r, err
 
 }
@@ -0,0 +1,158 @@
+package internalApi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver maps an apiName to the endpoints (host:port) serving it
+type Resolver interface {
+	Resolve(apiName string) ([]string, error)
+}
+
+// StaticResolver resolves every apiName to the same list of endpoints
+type StaticResolver []string
+
+func NewStaticResolver(endpoints ...string) StaticResolver {
+	return endpoints
+}
+
+func (s StaticResolver) Resolve(string) ([]string, error) {
+	if len(s) == 0 {
+		return nil, errors.New("no endpoints")
+	}
+	return s, nil
+}
+
+// srvResolver looks up endpoints from a DNS SRV record, results are cached for refresh
+type srvResolver struct {
+	service, proto, name string
+	refresh              time.Duration
+	lookup               func(service, proto, name string) (string, []*net.SRV, error)
+
+	lock      sync.Mutex
+	endpoints []string
+	expires   time.Time
+	looking   bool // a lookup is in flight
+}
+
+// NewSRVResolver resolves endpoints from the SRV record _service._proto.name. Only the
+// targets with the lowest priority are used, their weights are ignored as endpoints are
+// balanced by WithBalancer
+func NewSRVResolver(service, proto, name string, refresh time.Duration) Resolver {
+	return &srvResolver{service: service, proto: proto, name: name, refresh: refresh, lookup: net.LookupSRV}
+}
+
+func (s *srvResolver) Resolve(string) ([]string, error) {
+	s.lock.Lock()
+	if s.endpoints != nil && (time.Now().Before(s.expires) || s.looking) {
+		// fresh, or stale while another lookup is in flight
+		endpoints := s.endpoints
+		s.lock.Unlock()
+		return endpoints, nil
+	}
+	s.looking = true
+	s.lock.Unlock()
+
+	// lookups can be slow, they are done without the lock
+	_, records, err := s.lookup(s.service, s.proto, s.name)
+	if err == nil && len(records) == 0 {
+		err = fmt.Errorf("no SRV records for _%s._%s.%s", s.service, s.proto, s.name)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.looking = false
+	if err != nil {
+		if s.endpoints != nil {
+			// keep serving the last known endpoints
+			return s.endpoints, nil
+		}
+		return nil, err
+	}
+
+	// lowest priority targets only
+	var priority uint16 = math.MaxUint16
+	for _, record := range records {
+		if record.Priority < priority {
+			priority = record.Priority
+		}
+	}
+	var endpoints []string
+	for _, record := range records {
+		if record.Priority != priority {
+			continue
+		}
+		endpoints = append(endpoints, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), fmt.Sprint(record.Port)))
+	}
+	s.endpoints, s.expires = endpoints, time.Now().Add(s.refresh)
+	return s.endpoints, nil
+}
+
+// FileResolver reads endpoints from a file, one host:port per line ('#' for comments).
+// The file is polled for changes until Close is called
+type FileResolver struct {
+	path    string
+	watcher *fileWatcher
+
+	lock      sync.RWMutex
+	endpoints []string
+}
+
+func NewFileResolver(path string, interval time.Duration) (*FileResolver, error) {
+	f := &FileResolver{path: path}
+	f.watcher = newFileWatcher(path, f.load)
+	if err := f.watcher.reload(); err != nil {
+		return nil, err
+	}
+	go f.watcher.watch(interval, nil)
+	return f, nil
+}
+
+func (f *FileResolver) Resolve(string) ([]string, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	if len(f.endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints in %s : %v", f.path, f.watcher.lastErr())
+	}
+	return f.endpoints, nil
+}
+
+// Close stops watching the file
+func (f *FileResolver) Close() {
+	f.watcher.close()
+}
+
+// read the endpoints of the file
+func (f *FileResolver) load() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var endpoints []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		endpoints = append(endpoints, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.endpoints = endpoints
+	return nil
+}
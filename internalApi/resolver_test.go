@@ -0,0 +1,164 @@
+package internalApi
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Resolver_RoundRobin(t *testing.T) {
+	var hits [2]int64
+	var servers []string
+	for i := range hits {
+		i := i
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&hits[i], 1)
+		}))
+		defer s.Close()
+		servers = append(servers, strings.TrimPrefix(s.URL, "http://"))
+	}
+
+	api := NewNamed("v1")
+	api.Add("GetUser", "users", WithResolver(NewStaticResolver(servers...)))
+
+	for i := 0; i < 4; i++ {
+		req, _ := request.NewRequest(context.Background(), "/users", nil)
+		_, err := api.Get("GetUser", req)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(2), atomic.LoadInt64(&hits[0]))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&hits[1]))
+}
+
+func Test_Resolver_Ejection(t *testing.T) {
+	var healthyHits int64
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&healthyHits, 1)
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	api := NewNamed("v1")
+	api.Add("GetUser", "users", WithEjection(1, time.Minute), WithResolver(NewStaticResolver(
+		strings.TrimPrefix(failing.URL, "http://"), strings.TrimPrefix(healthy.URL, "http://"))))
+
+	var failed int
+	for i := 0; i < 5; i++ {
+		req, _ := request.NewRequest(context.Background(), "/users", nil)
+		if _, err := api.Get("GetUser", req); err != nil {
+			failed++
+		}
+	}
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, int64(4), atomic.LoadInt64(&healthyHits))
+}
+
+func Test_Resolver_LeastInflight(t *testing.T) {
+	pool := newEndpointPool("GetUser", &apiConfig{resolver: NewStaticResolver("a", "b"), balancer: LeastInflight})
+	a, _ := pool.pick()
+	b, _ := pool.pick()
+	assert.NotEqual(t, a.host, b.host)
+	pool.done(b, false)
+	c, _ := pool.pick()
+	assert.Equal(t, b.host, c.host)
+}
+
+type emptyResolver struct{}
+
+func (emptyResolver) Resolve(string) ([]string, error) {
+	return nil, nil
+}
+
+func Test_Resolver_Empty(t *testing.T) {
+	for _, balancer := range []Balancer{RoundRobin, LeastInflight, Random} {
+		pool := newEndpointPool("GetUser", &apiConfig{resolver: emptyResolver{}, balancer: balancer})
+		_, err := pool.pick()
+		assert.Error(t, err, balancer)
+	}
+
+	api := NewNamed("v1")
+	api.Add("GetUser", "users", WithResolver(emptyResolver{}))
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Get("GetUser", req)
+	assert.ErrorContains(t, err, "no endpoints")
+
+	s := NewSRVResolver("http", "tcp", "users.local", time.Minute).(*srvResolver)
+	s.lookup = func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", nil, nil
+	}
+	_, err = s.Resolve("GetUser")
+	assert.Error(t, err)
+}
+
+func Test_Resolver_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	assert.NoError(t, os.WriteFile(path, []byte("# users\nlocalhost:1\n\nlocalhost:2\n"), 0644))
+
+	f, err := NewFileResolver(path, 10*time.Millisecond)
+	assert.NoError(t, err)
+	defer f.Close()
+	endpoints, _ := f.Resolve("GetUser")
+	assert.Equal(t, []string{"localhost:1", "localhost:2"}, endpoints)
+
+	assert.NoError(t, os.WriteFile(path, []byte("localhost:3\n"), 0644))
+	assert.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	assert.Eventually(t, func() bool {
+		endpoints, _ := f.Resolve("GetUser")
+		return len(endpoints) == 1 && endpoints[0] == "localhost:3"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_Resolver_SRV(t *testing.T) {
+	s := NewSRVResolver("http", "tcp", "users.local", time.Minute).(*srvResolver)
+	s.lookup = func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", []*net.SRV{{Target: "users-1.local.", Port: 8080}, {Target: "users-2.local.", Port: 8080}}, nil
+	}
+	endpoints, err := s.Resolve("GetUser")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users-1.local:8080", "users-2.local:8080"}, endpoints)
+
+	// backup targets are left out
+	s = NewSRVResolver("http", "tcp", "users.local", time.Minute).(*srvResolver)
+	s.lookup = func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", []*net.SRV{{Target: "users-3.local.", Port: 8080, Priority: 20}, {Target: "users-1.local.", Port: 8080, Priority: 10}}, nil
+	}
+	endpoints, err = s.Resolve("GetUser")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users-1.local:8080"}, endpoints)
+
+	// stale endpoints are served while the record is looked up
+	lookup := make(chan bool)
+	s.expires = time.Now()
+	s.lookup = func(service, proto, name string) (string, []*net.SRV, error) {
+		<-lookup
+		return "", []*net.SRV{{Target: "users-2.local.", Port: 8080}}, nil
+	}
+	resolved := make(chan []string)
+	go func() {
+		endpoints, _ := s.Resolve("GetUser")
+		resolved <- endpoints
+	}()
+	assert.Eventually(t, func() bool {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return s.looking
+	}, time.Second, time.Millisecond)
+	endpoints, err = s.Resolve("GetUser")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users-1.local:8080"}, endpoints)
+	close(lookup)
+	assert.Equal(t, []string{"users-2.local:8080"}, <-resolved)
+}
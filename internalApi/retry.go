@@ -0,0 +1,97 @@
+package internalApi
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Retry retries failed requests for an apiName up to Attempts requests in total. Requests
+// are retried on connection errors and 429, 502, 503 and 504 responses, waiting Backoff
+// between attempts, doubled after each attempt up to MaxBackoff. Only idempotent methods
+// with a replayable body are retried
+type Retry struct {
+	Attempts   int           `json:"attempts" yaml:"attempts"`
+	Backoff    time.Duration `json:"backoff" yaml:"backoff"`
+	MaxBackoff time.Duration `json:"maxBackoff" yaml:"maxBackoff"`
+}
+
+// WithRetry retries failed requests for this apiName
+func WithRetry(retry Retry) ApiOption {
+	return func(config *apiConfig) {
+		config.retry = &retry
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var statusErr *StatusError
+		return isEndpointFailure(err) && !errors.As(err, &statusErr) && !errors.Is(err, ErrRateLimited)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff before the nth retry, with jitter
+func (r Retry) backoff(n int) time.Duration {
+	backoff := r.Backoff
+	for i := 1; i < n && (r.MaxBackoff <= 0 || backoff < r.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	// between half and the full backoff
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+func (r Retry) interceptor(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if r.Attempts <= 1 || !isIdempotent(req.Method) || !replayable {
+			return next(req)
+		}
+
+		for attempt := 1; ; attempt++ {
+			resp, err := next(req)
+			if attempt >= r.Attempts || !isRetryable(resp, err) {
+				return resp, err
+			}
+			if resp != nil {
+				_ = resp.Body.Close()
+			}
+
+			timer := time.NewTimer(r.backoff(attempt))
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, fmt.Errorf("waiting to retry : %w", req.Context().Err())
+			}
+
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package internalApi
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Retry(t *testing.T) {
+	api := NewNamed("v1")
+	api.Add("GetUser", "localhost:9999", WithRoute("GET /users"), WithRetry(Retry{Attempts: 3, Backoff: time.Millisecond}))
+	api.Add("AddUser", "localhost:9999", WithRoute("POST /users"), WithRetry(Retry{Attempts: 3, Backoff: time.Millisecond}))
+	getUser := api.Expect("GetUser").Respond(http.StatusServiceUnavailable, nil).Respond(http.StatusBadGateway, nil).Respond(http.StatusOK, []byte("user"))
+	addUser := api.Expect("AddUser").Respond(http.StatusServiceUnavailable, nil)
+
+	req, _ := request.NewRequest(context.Background(), "", nil)
+	rsp, err := api.Call("GetUser", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "user", string(rsp))
+	assert.Equal(t, 3, getUser.Calls())
+
+	// not idempotent
+	req, _ = request.NewRequest(context.Background(), "", strings.NewReader(`{}`))
+	_, err = api.Call("AddUser", req)
+	assert.Error(t, err)
+	assert.Equal(t, 1, addUser.Calls())
+
+	// replayable bodies are sent again
+	api.Add("PutUser", "localhost:9999", WithRoute("PUT /users"), WithRetry(Retry{Attempts: 2, Backoff: time.Millisecond}))
+	var bodies []string
+	api.ExpectHook("PutUser", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	req, _ = request.NewRequest(context.Background(), "", strings.NewReader(`{"name":"a"}`))
+	_, err = api.Call("PutUser", req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"name":"a"}`, `{"name":"a"}`}, bodies)
+
+	// retries are bounded by the attempts
+	registry := NewMetricsRegistry()
+	api.metrics = registry
+	api.InjectFault("GetUser", Fault{ErrorRate: 1})
+	req, _ = request.NewRequest(context.Background(), "", nil)
+	_, err = api.Call("GetUser", req)
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	assert.Equal(t, int64(3), registry.Requests("GetUser", http.StatusServiceUnavailable))
+}
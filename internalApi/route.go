@@ -0,0 +1,89 @@
+package internalApi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ErrPathParams is returned when the path params of a request do not match the apiName's route
+var ErrPathParams = errors.New("invalid path params")
+
+// parseRoute returns the names of the {params} in the path template, eg. /users/{id}
+func parseRoute(template string) ([]string, error) {
+	var params []string
+	for rest := template; ; {
+		open := strings.IndexAny(rest, "{}")
+		if open == -1 {
+			return params, nil
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("invalid route %s : unexpected }", template)
+		}
+		end := strings.IndexAny(rest[open+1:], "{}")
+		if end == -1 || rest[open+1+end] == '{' {
+			return nil, fmt.Errorf("invalid route %s : unclosed {", template)
+		}
+		name := rest[open+1 : open+1+end]
+		if name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid route %s : invalid param {%s}", template, name)
+		}
+		if contains(params, name) {
+			return nil, fmt.Errorf("invalid route %s : duplicate param {%s}", template, name)
+		}
+		params = append(params, name)
+		rest = rest[open+1+end+1:]
+	}
+}
+
+// expandRoute replaces the {params} in the path template with their escaped values. Every
+// param must be given a non-empty value and no other params are allowed
+func expandRoute(template string, names []string, params map[string]string) (string, error) {
+	var missing, unknown []string
+	for _, name := range names {
+		if params[name] == "" {
+			missing = append(missing, name)
+		}
+	}
+	for name := range params {
+		if !contains(names, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w : missing %s for %s", ErrPathParams, strings.Join(missing, ", "), template)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("%w : unknown %s for %s", ErrPathParams, strings.Join(unknown, ", "), template)
+	}
+
+	path := template
+	for _, name := range names {
+		val := params[name]
+		if val == "." || val == ".." {
+			return "", fmt.Errorf("%w : %s cannot be %s", ErrPathParams, name, val)
+		}
+		path = strings.Replace(path, "{"+name+"}", url.PathEscape(val), 1)
+	}
+	return path, nil
+}
+
+type routeKey struct{}
+
+// withRoute adds the route template to the context, for span names
+func withRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// spanName names outgoing request spans after their route template instead of the url
+func spanName(_ string, r *http.Request) string {
+	if route, ok := r.Context().Value(routeKey{}).(string); ok {
+		return r.Method + " " + route
+	}
+	return "HTTP " + r.Method
+}
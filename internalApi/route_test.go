@@ -0,0 +1,86 @@
+package internalApi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func Test_ParseRoute(t *testing.T) {
+	params, err := parseRoute("/users/{id}/posts/{postId}")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "postId"}, params)
+
+	params, err = parseRoute("/users")
+	assert.NoError(t, err)
+	assert.Empty(t, params)
+
+	for _, invalid := range []string{"/users/{id", "/users/id}", "/users/{}", "/users/{id}/{id}", "/users/{{id}}"} {
+		_, err = parseRoute(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func Test_Route(t *testing.T) {
+	provider := newRecordingProvider()
+	api := NewNamed("v1", WithTracingProvider(provider))
+	api.Add("GetPost", "localhost:9999", WithRoute("GET /users/{id}/posts/{postId}"))
+
+	var paths []string
+	api.ExpectHook("GetPost", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		paths = append(paths, r.URL.EscapedPath()+"?"+r.URL.RawQuery)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req, _ := request.NewRequest(context.Background(), "", nil,
+		request.WithPathParams(map[string]string{"id": "a b/c", "postId": "1"}),
+		request.WithQueryParams(map[string]string{"sort": "asc"}))
+	_, err := api.Call("GetPost", req)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/v1/users/a%20b%2Fc/posts/1?sort=asc"}, paths)
+
+	spans := provider.spans("GetPost")
+	assert.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Attributes(), attribute.Key("http.route").String("/v1/users/{id}/posts/{postId}"))
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, params := range []map[string]string{
+			{"id": "1"},
+			{"id": "1", "postId": ""},
+			{"id": "1", "postId": "2", "other": "3"},
+			{"id": "..", "postId": "2"},
+		} {
+			req, _ := request.NewRequest(context.Background(), "", nil, request.WithPathParams(params))
+			_, err := api.Call("GetPost", req)
+			assert.True(t, errors.Is(err, ErrPathParams), err)
+		}
+
+		// a path given by the request cannot take params
+		req, _ := request.NewRequest(context.Background(), "/users/1", nil, request.WithPathParams(map[string]string{"id": "1"}))
+		_, err := api.Call("GetPost", req)
+		assert.True(t, errors.Is(err, ErrPathParams), err)
+		assert.Len(t, paths, 1)
+	})
+
+	t.Run("invalid route", func(t *testing.T) {
+		api.Add("Invalid", "localhost:9999", WithRoute("GET /users/{id"))
+		req, _ := request.NewRequest(context.Background(), "", nil)
+		_, err := api.Call("Invalid", req)
+		assert.Error(t, err)
+	})
+}
+
+func Test_RouteSpanName(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/1", nil)
+	assert.Equal(t, "HTTP GET", spanName("", r))
+
+	r = r.WithContext(withRoute(r.Context(), "/v1/users/{id}"))
+	assert.Equal(t, "GET /v1/users/{id}", spanName("", r))
+}
@@ -0,0 +1,495 @@
+package internalApi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationMode sets how bodies not matching their schema are handled
+type ValidationMode int
+
+const (
+	// ValidateStrict fails the request with a *ValidationError
+	ValidateStrict ValidationMode = iota
+	// ValidateLenient logs a warning and lets the request through
+	ValidateLenient
+)
+
+// Schema is a compiled JSON Schema, see CompileSchema and OpenAPI
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// CompileSchema compiles a JSON Schema document, draft 2019-09 unless it sets $schema
+func CompileSchema(schema []byte) (*Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("schema.json", bytes.NewReader(schema)); err != nil {
+		return nil, fmt.Errorf("invalid schema : %v", err)
+	}
+	s, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema : %v", err)
+	}
+	return &Schema{schema: s}, nil
+}
+
+// Validate returns the violations of the json body, none if it is valid
+func (s *Schema) Validate(body []byte) []Violation {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return []Violation{{Location: "", Message: "invalid json : " + err.Error()}}
+	}
+	if decoder.More() {
+		return []Violation{{Location: "", Message: "invalid json : trailing data"}}
+	}
+
+	err := s.schema.Validate(v)
+	if err == nil {
+		return nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []Violation{{Location: "", Message: err.Error()}}
+	}
+	// report the leaves, their parents only say that a sub schema failed
+	var violations []Violation
+	var leaves func(*jsonschema.ValidationError)
+	leaves = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			violations = append(violations, Violation{Location: ve.InstanceLocation, Message: ve.Message})
+		}
+		for _, cause := range ve.Causes {
+			leaves(cause)
+		}
+	}
+	leaves(ve)
+	return violations
+}
+
+// Violation of a schema at Location, a json pointer into the body
+type Violation struct {
+	Location string
+	Message  string
+}
+
+func (v Violation) String() string {
+	location := v.Location
+	if location == "" {
+		location = "/"
+	}
+	return location + " : " + v.Message
+}
+
+// ValidationError is returned in strict mode for bodies not matching their schema
+type ValidationError struct {
+	ApiName    string
+	Response   bool // false for the request body
+	StatusCode int  // of the response
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	body := "request"
+	if e.Response {
+		body = fmt.Sprintf("response (%d)", e.StatusCode)
+	}
+	violations := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		violations[i] = v.String()
+	}
+	return fmt.Sprintf("%s NamedApi %s body does not match schema : %s", e.ApiName, body, strings.Join(violations, "; "))
+}
+
+// schemas validating the bodies of an apiName
+type schemas struct {
+	mode      ValidationMode
+	request   *Schema
+	responses map[string]*Schema // status code, eg. 200, class, eg. 2XX, or default
+}
+
+func (s *schemas) response(status int) *Schema {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", "default"} {
+		if schema := s.responses[key]; schema != nil {
+			return schema
+		}
+	}
+	return nil
+}
+
+func (c *apiConfig) schemas() *schemas {
+	if c.validation == nil {
+		c.validation = &schemas{responses: map[string]*Schema{}}
+	}
+	return c.validation
+}
+
+// WithRequestSchema validates request bodies for this apiName
+func WithRequestSchema(schema *Schema) ApiOption {
+	return func(config *apiConfig) {
+		config.schemas().request = schema
+	}
+}
+
+// WithResponseSchema validates json response bodies with the status code, 0 for any status
+// without a schema of its own
+func WithResponseSchema(status int, schema *Schema) ApiOption {
+	return func(config *apiConfig) {
+		key := "default"
+		if status != 0 {
+			key = strconv.Itoa(status)
+		}
+		config.schemas().responses[key] = schema
+	}
+}
+
+// WithValidationMode sets how invalid bodies are handled, ValidateStrict by default
+func WithValidationMode(mode ValidationMode) ApiOption {
+	return func(config *apiConfig) {
+		config.schemas().mode = mode
+	}
+}
+
+// WithOpenAPI validates the request and response bodies with the schemas of the operation
+func WithOpenAPI(doc *OpenAPI, operationId string) ApiOption {
+	return func(config *apiConfig) {
+		request, responses, err := doc.Schemas(operationId)
+		if err != nil {
+			config.err = err
+			return
+		}
+		s := config.schemas()
+		s.request = request
+		for key, schema := range responses {
+			s.responses[key] = schema
+		}
+	}
+}
+
+// interceptor validating the bodies for apiName. Response bodies over maxBodySize are not
+// validated, reading them fails later on
+func withValidation(logger Logger, apiName string, s *schemas, maxBodySize int64) Interceptor {
+	invalid := func(req *http.Request, err *ValidationError) error {
+		span := trace.SpanFromContext(req.Context())
+		span.SetAttributes(attribute.Key("schema-violations").Int(len(err.Violations)))
+		if s.mode == ValidateLenient {
+			withTrace(req.Context(), logger).Warn(err.Error(), "url", req.URL.String(), "method", req.Method)
+			return nil
+		}
+		return err
+	}
+
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if s.request != nil {
+				body, err := replayableBody(req)
+				if err != nil {
+					return nil, err
+				}
+				// requests without a body, eg. GET, are not validated
+				if len(body) > 0 {
+					if violations := s.request.Validate(body); len(violations) > 0 {
+						if err := invalid(req, &ValidationError{ApiName: apiName, Violations: violations}); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+
+			resp, err := next(req)
+			if err != nil {
+				return resp, err
+			}
+			schema := s.response(resp.StatusCode)
+			noBody := resp.StatusCode == http.StatusNoContent || req.Method == http.MethodHead || resp.ContentLength == 0
+			if schema == nil || noBody || !isJSON(resp.Header.Get("Content-Type")) {
+				return resp, nil
+			}
+
+			var body []byte
+			if maxBodySize > 0 {
+				body, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+			} else {
+				body, err = ioutil.ReadAll(resp.Body)
+			}
+			if err != nil {
+				_ = resp.Body.Close()
+				return nil, err
+			}
+			if maxBodySize > 0 && int64(len(body)) > maxBodySize {
+				resp.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+				return resp, nil
+			}
+			_ = resp.Body.Close()
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			// like requests, empty bodies are not validated
+			if len(body) == 0 {
+				return resp, nil
+			}
+			if violations := schema.Validate(body); len(violations) > 0 {
+				err := invalid(req, &ValidationError{ApiName: apiName, Response: true, StatusCode: resp.StatusCode, Violations: violations})
+				if err != nil {
+					return nil, err
+				}
+			}
+			return resp, nil
+		}
+	}
+}
+
+// reads the request body, leaving it in place to be sent
+func replayableBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+// json or +json media types, a missing content type is assumed to be json
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// OpenAPI is a parsed OpenAPI 3 document, its operations' schemas can validate named apis,
+// see WithOpenAPI
+type OpenAPI struct {
+	doc      map[string]interface{}
+	compiler *jsonschema.Compiler
+}
+
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// LoadOpenAPI reads an OpenAPI 3.0 or 3.1 document in json or yaml
+func LoadOpenAPI(r io.Reader) (*OpenAPI, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil { // yaml is a superset of json
+		return nil, fmt.Errorf("invalid openapi document : %v", err)
+	}
+	doc, _ := stringKeys(v).(map[string]interface{})
+	version, _ := doc["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("invalid openapi document : unsupported version %q", version)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if strings.HasPrefix(version, "3.0") {
+		// 3.0 schemas are draft 4 with nullable
+		compiler.Draft = jsonschema.Draft4
+		nullable(doc)
+	} else {
+		compiler.Draft = jsonschema.Draft2020
+	}
+	b, err = json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid openapi document : %v", err)
+	}
+	if err := compiler.AddResource("openapi.json", bytes.NewReader(b)); err != nil {
+		return nil, fmt.Errorf("invalid openapi document : %v", err)
+	}
+	return &OpenAPI{doc: doc, compiler: compiler}, nil
+}
+
+func LoadOpenAPIFile(path string) (*OpenAPI, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadOpenAPI(f)
+}
+
+// yaml maps with non string keys, eg. status codes, as json objects
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, val := range v {
+			m[fmt.Sprint(key)] = stringKeys(val)
+		}
+		return m
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = stringKeys(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = stringKeys(val)
+		}
+	}
+	return v
+}
+
+// rewrites "nullable: true" as a "null" type
+func nullable(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if n, _ := v["nullable"].(bool); n {
+			if t, ok := v["type"].(string); ok {
+				v["type"] = []interface{}{t, "null"}
+			}
+		}
+		delete(v, "nullable")
+		for _, val := range v {
+			nullable(val)
+		}
+	case []interface{}:
+		for _, val := range v {
+			nullable(val)
+		}
+	}
+}
+
+// OperationIds returns the ids of the document's operations, sorted
+func (o *OpenAPI) OperationIds() []string {
+	var ids []string
+	paths, _ := o.doc["paths"].(map[string]interface{})
+	for _, item := range paths {
+		item, _ := item.(map[string]interface{})
+		for _, method := range openAPIMethods {
+			if op, ok := item[method].(map[string]interface{}); ok {
+				if id, ok := op["operationId"].(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Schemas returns the json schemas of the operation's request body, nil if it has none, and
+// responses by status code (as in the document, eg. 200, 2XX or default)
+func (o *OpenAPI) Schemas(operationId string) (request *Schema, responses map[string]*Schema, err error) {
+	ptr, op := o.operation(operationId)
+	if op == nil {
+		return nil, nil, fmt.Errorf("openapi operation %s not found", operationId)
+	}
+
+	if body, bodyPtr := o.resolve(op["requestBody"], ptr+"/requestBody"); body != nil {
+		if request, err = o.mediaSchema(body, bodyPtr); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	responses = map[string]*Schema{}
+	rsps, _ := op["responses"].(map[string]interface{})
+	for status, rsp := range rsps {
+		rsp, rspPtr := o.resolve(rsp, ptr+"/responses/"+escapePointer(status))
+		if rsp == nil {
+			continue
+		}
+		schema, err := o.mediaSchema(rsp, rspPtr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if schema != nil {
+			responses[strings.ToUpper(status)] = schema
+		}
+	}
+	return request, responses, nil
+}
+
+// pointer to and the operation with operationId
+func (o *OpenAPI) operation(operationId string) (string, map[string]interface{}) {
+	paths, _ := o.doc["paths"].(map[string]interface{})
+	for path, item := range paths {
+		item, _ := item.(map[string]interface{})
+		for _, method := range openAPIMethods {
+			if op, ok := item[method].(map[string]interface{}); ok && op["operationId"] == operationId {
+				return "/paths/" + escapePointer(path) + "/" + method, op
+			}
+		}
+	}
+	return "", nil
+}
+
+// follows a local $ref, eg. to #/components/requestBodies
+func (o *OpenAPI) resolve(v interface{}, ptr string) (map[string]interface{}, string) {
+	obj, _ := v.(map[string]interface{})
+	for i := 0; obj != nil && i < 10; i++ {
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			return obj, ptr
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, ""
+		}
+		ptr = strings.TrimPrefix(ref, "#")
+		var target interface{} = o.doc
+		for _, token := range strings.Split(ptr[1:], "/") {
+			m, _ := target.(map[string]interface{})
+			target = m[unescapePointer(token)]
+		}
+		obj, _ = target.(map[string]interface{})
+	}
+	return obj, ptr
+}
+
+// compiles the json schema of a request body or response object
+func (o *OpenAPI) mediaSchema(obj map[string]interface{}, ptr string) (*Schema, error) {
+	content, _ := obj["content"].(map[string]interface{})
+	var mediaTypes []string
+	for mediaType := range content {
+		if isJSON(mediaType) {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	if len(mediaTypes) == 0 {
+		return nil, nil
+	}
+	// application/json first
+	sort.Slice(mediaTypes, func(i, j int) bool {
+		return mediaTypes[i] == "application/json" || (mediaTypes[j] != "application/json" && mediaTypes[i] < mediaTypes[j])
+	})
+	media, _ := content[mediaTypes[0]].(map[string]interface{})
+	if _, ok := media["schema"]; !ok {
+		return nil, nil
+	}
+	s, err := o.compiler.Compile("openapi.json#" + ptr + "/content/" + escapePointer(mediaTypes[0]) + "/schema")
+	if err != nil {
+		return nil, fmt.Errorf("invalid openapi schema at %s : %v", ptr, err)
+	}
+	return &Schema{schema: s}, nil
+}
+
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func unescapePointer(token string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
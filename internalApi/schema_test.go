@@ -0,0 +1,188 @@
+package internalApi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["name"],
+	"properties": {
+		"name": {"type": "string"},
+		"age": {"type": "integer", "minimum": 0}
+	}
+}`
+
+const openAPIDoc = `
+openapi: 3.0.3
+info:
+  title: users
+  version: v1
+paths:
+  /users:
+    post:
+      operationId: AddUser
+      requestBody:
+        $ref: '#/components/requestBodies/User'
+      responses:
+        201:
+          description: created
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+        default:
+          description: error
+          content:
+            application/problem+json:
+              schema:
+                type: object
+                required: [title]
+  /users/{id}:
+    get:
+      operationId: GetUser
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+components:
+  requestBodies:
+    User:
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/User'
+  schemas:
+    User:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: string
+        email:
+          type: string
+          nullable: true
+`
+
+func Test_SchemaValidate(t *testing.T) {
+	schema, err := CompileSchema([]byte(userSchema))
+	assert.NoError(t, err)
+
+	assert.Empty(t, schema.Validate([]byte(`{"name": "a", "age": 1}`)))
+	violations := schema.Validate([]byte(`{"age": -1}`))
+	assert.Len(t, violations, 2)
+	violations = schema.Validate([]byte(`{"name": 1}`))
+	assert.Len(t, violations, 1)
+	assert.Equal(t, "/name", violations[0].Location)
+	assert.NotEmpty(t, schema.Validate([]byte(`{"name"`)))
+
+	_, err = CompileSchema([]byte(`{"type": 1}`))
+	assert.Error(t, err)
+}
+
+func Test_SchemaValidation(t *testing.T) {
+	schema, _ := CompileSchema([]byte(userSchema))
+
+	api := NewNamed("v1")
+	api.Add("AddUser", "localhost:9999", WithRoute("POST /users"), WithRequestSchema(schema), WithResponseSchema(http.StatusCreated, schema))
+	api.Add("AddUserLenient", "localhost:9999", WithRoute("POST /users"), WithRequestSchema(schema), WithResponseSchema(0, schema), WithValidationMode(ValidateLenient))
+	expectAdd := api.Expect("AddUser").RespondJSON(http.StatusCreated, map[string]interface{}{"name": "a"})
+	api.Expect("AddUserLenient").RespondJSON(http.StatusCreated, map[string]interface{}{"age": 1})
+
+	call := func(apiName, body string) ([]byte, error) {
+		req, _ := request.NewRequest(context.Background(), "", strings.NewReader(body))
+		return api.Call(apiName, req)
+	}
+
+	rsp, err := call("AddUser", `{"name": "a"}`)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "a"}`, string(rsp))
+
+	// invalid requests are not sent
+	_, err = call("AddUser", `{"name": 1}`)
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.False(t, validationErr.Response)
+	assert.Equal(t, "AddUser", validationErr.ApiName)
+	assert.Contains(t, err.Error(), "/name")
+	assert.Equal(t, 1, expectAdd.Calls())
+
+	// lenient mode lets an invalid request and response through
+	rsp, err = call("AddUserLenient", `{"age": "1"}`)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"age": 1}`, string(rsp))
+
+	// empty responses are not validated
+	api.Add("DeleteUser", "localhost:9999", WithRoute("DELETE /users"), WithResponseSchema(0, schema))
+	api.ExpectHook("DeleteUser", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req, _ := request.NewRequest(context.Background(), "", nil)
+	_, err = api.Call("DeleteUser", req)
+	assert.NoError(t, err)
+
+	// responses over the max body size are not validated
+	api.Add("ListUsers", "localhost:9999", WithRoute("GET /users"), WithResponseSchema(0, schema), WithApiMaxBodySize(8))
+	api.ExpectHook("ListUsers", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"name": "` + strings.Repeat("a", 16) + `"}`))
+	})
+	_, err = api.Call("ListUsers", req)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+}
+
+func Test_OpenAPISchemas(t *testing.T) {
+	doc, err := LoadOpenAPI(strings.NewReader(openAPIDoc))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"AddUser", "GetUser"}, doc.OperationIds())
+
+	request, responses, err := doc.Schemas("AddUser")
+	assert.NoError(t, err)
+	assert.NotNil(t, request)
+	assert.Len(t, responses, 2)
+	assert.Empty(t, request.Validate([]byte(`{"name": "a", "email": null}`)))
+	assert.NotEmpty(t, request.Validate([]byte(`{"email": "a@b.c"}`)))
+	assert.Empty(t, responses["DEFAULT"].Validate([]byte(`{"title": "bad request"}`)))
+
+	request, responses, err = doc.Schemas("GetUser")
+	assert.NoError(t, err)
+	assert.Nil(t, request)
+	assert.Len(t, responses, 1)
+
+	_, _, err = doc.Schemas("Unknown")
+	assert.Error(t, err)
+
+	_, err = LoadOpenAPI(bytes.NewReader([]byte(`{"swagger": "2.0"}`)))
+	assert.Error(t, err)
+}
+
+func Test_OpenAPIValidation(t *testing.T) {
+	doc, _ := LoadOpenAPI(strings.NewReader(openAPIDoc))
+
+	api := NewNamed("v1")
+	api.Add("GetUser", "localhost:9999", WithRoute("GET /users/{id}"), WithOpenAPI(doc, "GetUser"))
+	api.Add("Unknown", "localhost:9999", WithOpenAPI(doc, "Unknown"))
+	api.Expect("GetUser").RespondJSON(http.StatusOK, map[string]interface{}{"email": "a@b.c"})
+
+	req, _ := request.NewRequest(context.Background(), "", nil, request.WithPathParams(map[string]string{"id": "1"}))
+	_, err := api.Call("GetUser", req)
+	var validationErr *ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+	assert.True(t, validationErr.Response)
+	assert.Equal(t, http.StatusOK, validationErr.StatusCode)
+
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Get("Unknown", req)
+	assert.Error(t, err)
+}
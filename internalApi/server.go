@@ -0,0 +1,163 @@
+package internalApi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+Server
+- handlers are registered by the same apiNames the NamedApi client uses
+- routes are prefixed with the version
+- server spans are named by apiName
+*/
+
+// Route is a handler registered on the Server
+type Route struct {
+	ApiName string `json:"apiName"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+}
+
+type Server struct {
+	version   string
+	router    *mux.Router
+	versioned *mux.Router
+	routes    []Route
+}
+
+type ServerOption func(*Server)
+
+// WithServerTracing traces requests using the global tracer provider (see tracing.Init),
+// spans are named by apiName
+func WithServerTracing(service string) ServerOption {
+	return func(s *Server) {
+		s.router.Use(otelmux.Middleware(service))
+	}
+}
+
+// WithMiddleware adds middleware for every route
+func WithMiddleware(middleware ...mux.MiddlewareFunc) ServerOption {
+	return func(s *Server) {
+		s.router.Use(middleware...)
+	}
+}
+
+// WithBasicAuth rejects requests without valid basic auth credentials
+func WithBasicAuth(valid func(username, password string) bool) ServerOption {
+	return WithMiddleware(BasicAuth(valid))
+}
+
+// WithRouteListing serves the registered routes as json on path, outside the version prefix
+func WithRouteListing(path string) ServerOption {
+	return func(s *Server) {
+		s.router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(s.Routes())
+		}).Methods(http.MethodGet)
+	}
+}
+
+// BasicAuth middleware, a nil valid func accepts any credentials
+func BasicAuth(valid func(username, password string) bool) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok || (valid != nil && !valid(username, password)) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NewServer with routes prefixed by version. Deadlines propagated by NamedApi clients are
+// set on the request's context
+func NewServer(version string, options ...ServerOption) *Server {
+	s := &Server{version: version, router: mux.NewRouter()}
+	s.router.Use(DeadlineMiddleware)
+
+	for _, option := range options {
+		option(s)
+	}
+
+	s.versioned = s.router
+	if prefix := basePath(version); prefix != "" {
+		s.versioned = s.router.PathPrefix(prefix).Subrouter()
+	}
+	return s
+}
+
+// Handle registers the handler for apiName at method and path (relative to the version).
+// The path can be a route template with {params}, see mux.Vars
+func (s *Server) Handle(apiName, method, path string, handler http.HandlerFunc) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	method = strings.ToUpper(method)
+	for _, route := range s.routes {
+		if route.ApiName == apiName {
+			panic("already added handler " + apiName)
+		}
+	}
+
+	route := Route{ApiName: apiName, Method: method, Path: basePath(s.version) + path}
+	s.versioned.Handle(path, named(route, handler)).Methods(method).Name(apiName)
+	s.routes = append(s.routes, route)
+}
+
+// HandleNamed registers the handler for apiName at the method and path it was added with
+// on the client, see WithMethod and WithPath
+func (s *Server) HandleNamed(na *NamedApi, apiName string, handler http.HandlerFunc) error {
+	na.lock.RLock()
+	config := na.apis[apiName]
+	na.lock.RUnlock()
+	if config == nil {
+		return fmt.Errorf("%s NamedApi not added", apiName)
+	}
+	if config.method == "" || config.path == "" {
+		return fmt.Errorf("%s NamedApi added without method or path", apiName)
+	}
+	s.Handle(apiName, config.method, config.path, handler)
+	return nil
+}
+
+// Routes returns the registered routes sorted by path and method
+func (s *Server) Routes() []Route {
+	routes := append([]Route{}, s.routes...)
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// Router returns the underlying router, eg. to serve static files
+func (s *Server) Router() *mux.Router {
+	return s.router
+}
+
+// names the request's span after apiName
+func named(route Route, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(route.ApiName)
+		span.SetAttributes(attribute.Key("api-name").String(route.ApiName), attribute.Key("http.route").String(route.Path))
+		handler(w, r)
+	})
+}
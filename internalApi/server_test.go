@@ -0,0 +1,74 @@
+package internalApi
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/Ishan27g/internalApi/test/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Server(t *testing.T) {
+	var user = server.User{}
+	var hasDeadline bool
+
+	s := NewServer(server.Version, WithServerTracing("users"), WithRouteListing("/routes"),
+		WithBasicAuth(func(username, password string) bool {
+			return password == "ok"
+		}))
+
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	host := strings.TrimPrefix(ts.URL, "http://")
+
+	api := NewNamed(server.Version)
+	api.Add(server.GetUserApi, host, WithMethod(http.MethodGet), WithPath("/users"))
+	api.Add(server.AddUserApi, host, WithMethod(http.MethodPost), WithPath("/users"))
+
+	assert.NoError(t, s.HandleNamed(api, server.GetUserApi, func(w http.ResponseWriter, r *http.Request) {
+		_, hasDeadline = r.Context().Deadline()
+		_ = json.NewEncoder(w).Encode(user)
+	}))
+	assert.NoError(t, s.HandleNamed(api, server.AddUserApi, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&user)
+	}))
+	s.Handle("Health", http.MethodGet, "health", func(w http.ResponseWriter, r *http.Request) {})
+	assert.Error(t, s.HandleNamed(api, "Unknown", nil))
+
+	req, _ := request.NewRequest(context.Background(), "", strings.NewReader(`{"Name":"user123"}`), request.WithAuthBasic("any", "ok"))
+	_, err := api.Call(server.AddUserApi, req)
+	assert.NoError(t, err)
+
+	req, _ = request.NewRequest(context.Background(), "", nil, request.WithAuthBasic("any", "ok"), request.WithTimeout(time.Second))
+	b, err := api.Call(server.GetUserApi, req)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Name":"user123"}`, string(b))
+	assert.True(t, hasDeadline)
+
+	// auth
+	req, _ = request.NewRequest(context.Background(), "", nil, request.WithAuthBasic("any", "wrong"))
+	_, err = api.Call(server.GetUserApi, req)
+	assert.Error(t, err)
+
+	r, _ := http.NewRequest(http.MethodGet, ts.URL+"/routes", nil)
+	r.SetBasicAuth("any", "ok")
+	rsp, err := http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	defer rsp.Body.Close()
+	b, _ = ioutil.ReadAll(rsp.Body)
+
+	var routes []Route
+	assert.NoError(t, json.Unmarshal(b, &routes))
+	assert.Equal(t, []Route{
+		{ApiName: "Health", Method: http.MethodGet, Path: "/v1/health"},
+		{ApiName: server.GetUserApi, Method: http.MethodGet, Path: "/v1/users"},
+		{ApiName: server.AddUserApi, Method: http.MethodPost, Path: "/v1/users"},
+	}, routes)
+}
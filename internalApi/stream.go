@@ -0,0 +1,196 @@
+package internalApi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Ishan27g/internalApi/request"
+)
+
+// StreamResponse is a response with its body left unread. Body must be closed.
+// The http.Client timeout also applies while reading the body, use WithHttpClient
+// without a timeout for long-lived streams
+type StreamResponse struct {
+	StatusCode    int
+	Status        string
+	Header        http.Header
+	ContentLength int64
+	Body          io.ReadCloser
+}
+
+// streamBody ends the request once the body is closed
+type streamBody struct {
+	io.ReadCloser
+	done        func(err error)
+	once        sync.Once
+	maxLineSize int64 // the apiName's max body size
+
+	lock sync.Mutex
+	err  error
+}
+
+func (b *streamBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.lock.Lock()
+		b.err = err
+		b.lock.Unlock()
+	}
+	return n, err
+}
+
+func (b *streamBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		b.done(b.err)
+	})
+	return err
+}
+
+// Stream sends the request and returns the response without reading its body. Responses
+// are not validated, cached or recorded to cassettes
+func (na *NamedApi) Stream(apiName string, method string, req *request.Request) (*StreamResponse, error) {
+	resp, done, err := na.send(apiName, method, req, true)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamResponse{
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+		Body:          &streamBody{ReadCloser: resp.Body, done: done, maxLineSize: na.maxBodySizeFor(apiName)},
+	}, nil
+}
+
+// maxSSELineSize for apis without a max body size
+const maxSSELineSize = 1 << 20
+
+// Event is a server-sent event
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry int
+}
+
+// DecodeSSE sends the server-sent events read from body until it ends or ctx is done.
+// Body is closed once done, a read error is sent on the error channel before both
+// channels are closed. Lines are limited to the max body size of the streamed apiName,
+// 1MB if it has none, bufio.ErrTooLong is sent for longer lines
+func DecodeSSE(ctx context.Context, body io.ReadCloser) (<-chan Event, <-chan error) {
+	events, errs := make(chan Event), make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(events)
+		stop := closeOnDone(ctx, body)
+		defer stop()
+
+		var event Event
+		var data []string
+		maxLineSize := int64(maxSSELineSize)
+		if s, ok := body.(*streamBody); ok && s.maxLineSize > 0 {
+			maxLineSize = s.maxLineSize
+		}
+		scanner := bufio.NewScanner(body)
+		size := int64(bufio.MaxScanTokenSize)
+		if maxLineSize < size {
+			size = maxLineSize
+		}
+		scanner.Buffer(make([]byte, 0, size), int(maxLineSize))
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				// dispatch
+				if len(data) > 0 {
+					event.Data = strings.Join(data, "\n")
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						errs <- ctx.Err()
+						return
+					}
+				}
+				event, data = Event{ID: event.ID}, nil
+				continue
+			}
+			if strings.HasPrefix(line, ":") {
+				continue
+			}
+			field, val, _ := strings.Cut(line, ":")
+			val = strings.TrimPrefix(val, " ")
+			switch field {
+			case "id":
+				event.ID = val
+			case "event":
+				event.Event = val
+			case "data":
+				data = append(data, val)
+			case "retry":
+				event.Retry, _ = strconv.Atoi(val)
+			}
+		}
+		if ctx.Err() != nil {
+			errs <- ctx.Err()
+		} else if err := scanner.Err(); err != nil {
+			errs <- err
+		}
+	}()
+	return events, errs
+}
+
+// DecodeNDJSON sends each newline delimited json value read from body until it ends or ctx
+// is done. Body is closed once done, a read or decode error is sent on the error channel
+// before both channels are closed
+func DecodeNDJSON[T any](ctx context.Context, body io.ReadCloser) (<-chan T, <-chan error) {
+	values, errs := make(chan T), make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(values)
+		stop := closeOnDone(ctx, body)
+		defer stop()
+
+		decoder := json.NewDecoder(body)
+		for {
+			var v T
+			if err := decoder.Decode(&v); err != nil {
+				if ctx.Err() != nil {
+					errs <- ctx.Err()
+				} else if err != io.EOF {
+					errs <- err
+				}
+				return
+			}
+			select {
+			case values <- v:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return values, errs
+}
+
+// closes body when ctx is done to unblock reads, stop closes it otherwise
+func closeOnDone(ctx context.Context, body io.Closer) (stop func()) {
+	quit := make(chan bool)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-quit:
+		}
+		_ = body.Close()
+	}()
+	return func() {
+		close(quit)
+	}
+}
@@ -0,0 +1,136 @@
+package internalApi
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Stream(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := 1; i <= 3; i++ {
+				fmt.Fprintf(w, ": comment\nid: %d\nevent: user\ndata: user%d\ndata: added\n\n", i, i)
+				w.(http.Flusher).Flush()
+			}
+		case "/v1/users":
+			for i := 1; i <= 3; i++ {
+				fmt.Fprintf(w, "{\"Name\":\"user%d\"}\n", i)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	api := NewNamed("v1")
+	api.Add("Events", host)
+	api.Add("Users", host)
+
+	req, _ := request.NewRequest(context.Background(), "/events", nil)
+	rsp, err := api.Stream("Events", http.MethodGet, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/event-stream", rsp.Header.Get("Content-Type"))
+
+	events, errs := DecodeSSE(context.Background(), rsp.Body)
+	var received []Event
+	for event := range events {
+		received = append(received, event)
+	}
+	assert.NoError(t, <-errs)
+	assert.Len(t, received, 3)
+	assert.Equal(t, Event{ID: "3", Event: "user", Data: "user3\nadded"}, received[2])
+
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	rsp, err = api.Stream("Users", http.MethodGet, req)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	users, errs := DecodeNDJSON[struct{ Name string }](ctx, rsp.Body)
+	assert.Equal(t, "user1", (<-users).Name)
+	cancel()
+	for range users {
+	}
+	assert.True(t, errors.Is(<-errs, context.Canceled))
+}
+
+func Test_StreamUnbuffered(t *testing.T) {
+	large := strings.Repeat("a", 100*1024)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\n", large)
+		w.(http.Flusher).Flush()
+		// the stream stays open
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	api := NewNamed("v1", WithCache(NewLRUCache(10)))
+	api.Add("Events", host)
+	api.Add("SmallEvents", host, WithApiMaxBodySize(1024))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := request.NewRequest(ctx, "/events", nil)
+	rsp, err := api.Stream("Events", http.MethodGet, req)
+	assert.NoError(t, err)
+	events, errs := DecodeSSE(ctx, rsp.Body)
+	assert.Equal(t, large, (<-events).Data)
+	cancel()
+	for range events {
+	}
+	assert.True(t, errors.Is(<-errs, context.Canceled))
+
+	// lines are limited to the max body size
+	req, _ = request.NewRequest(context.Background(), "/events", nil)
+	rsp, err = api.Stream("SmallEvents", http.MethodGet, req)
+	assert.NoError(t, err)
+	events, errs = DecodeSSE(context.Background(), rsp.Body)
+	for range events {
+	}
+	assert.True(t, errors.Is(<-errs, bufio.ErrTooLong))
+}
+
+func Test_MaxBodySize(t *testing.T) {
+	api := NewNamed("v1", WithMaxBodySize(4))
+	api.Add("Small", "localhost:9999")
+	api.Add("Large", "localhost:9999", WithApiMaxBodySize(1024))
+
+	hook := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("too large"))
+	}
+	api.ExpectHook("Small", hook)
+	api.ExpectHook("Large", hook)
+
+	req, _ := request.NewRequest(context.Background(), "/", nil)
+	_, err := api.Get("Small", req)
+	assert.True(t, errors.Is(err, ErrBodyTooLarge))
+
+	req, _ = request.NewRequest(context.Background(), "/", nil)
+	b, err := api.Get("Large", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "too large", string(b))
+
+	// streams are not limited
+	req, _ = request.NewRequest(context.Background(), "/", nil)
+	rsp, err := api.Stream("Small", http.MethodGet, req)
+	assert.NoError(t, err)
+	b, _ = ioutil.ReadAll(rsp.Body)
+	assert.NoError(t, rsp.Body.Close())
+	assert.Equal(t, "too large", string(b))
+
+	_, err = api.Stream("Unknown", http.MethodGet, req)
+	assert.Error(t, err)
+}
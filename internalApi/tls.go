@@ -0,0 +1,170 @@
+package internalApi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// hostConfig holds the optional settings for a host
+type hostConfig struct {
+	scheme   string
+	basePath *string
+	tls      *tls.Config
+	limiter  *tokenBucket
+	err      error
+}
+
+type HostOption func(*hostConfig)
+
+// WithScheme sets the url scheme for the host, defaults to http
+func WithScheme(scheme string) HostOption {
+	return func(config *hostConfig) {
+		config.scheme = strings.ToLower(scheme)
+	}
+}
+
+// WithHostBasePath overrides the base path (NamedApi's version by default) for the host
+func WithHostBasePath(path string) HostOption {
+	return func(config *hostConfig) {
+		path = basePath(path)
+		config.basePath = &path
+	}
+}
+
+// WithTLS uses https with the tls config for the host
+func WithTLS(tlsConfig *tls.Config) HostOption {
+	return func(config *hostConfig) {
+		config.tls = tlsConfig.Clone()
+	}
+}
+
+// WithCA uses https, trusting the PEM encoded certificates for the host
+func WithCA(pem []byte) HostOption {
+	return func(config *hostConfig) {
+		pool := config.tlsConfig().RootCAs
+		if pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			config.err = errors.New("no certificates found in CA")
+			return
+		}
+		config.tlsConfig().RootCAs = pool
+	}
+}
+
+// WithCAFile uses https, trusting the CA bundle for the host
+func WithCAFile(path string) HostOption {
+	return func(config *hostConfig) {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			config.err = fmt.Errorf("error reading CA : %v", err)
+			return
+		}
+		WithCA(pem)(config)
+	}
+}
+
+// WithClientCert uses https, presenting the client certificate to the host (mTLS)
+func WithClientCert(certFile, keyFile string) HostOption {
+	return func(config *hostConfig) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			config.err = fmt.Errorf("error loading client certificate : %v", err)
+			return
+		}
+		config.tlsConfig().Certificates = append(config.tlsConfig().Certificates, cert)
+	}
+}
+
+// WithServerName uses https, verifying the host's certificate against name
+func WithServerName(name string) HostOption {
+	return func(config *hostConfig) {
+		config.tlsConfig().ServerName = name
+	}
+}
+
+func (config *hostConfig) tlsConfig() *tls.Config {
+	if config.tls == nil {
+		config.tls = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return config.tls
+}
+
+// AddHost configures the scheme, tls, base path and rate limit for requests to host
+func (na *NamedApi) AddHost(host string, options ...HostOption) error {
+	config := &hostConfig{}
+	if scheme, h := splitScheme(host); scheme != "" {
+		config.scheme, host = scheme, h
+	}
+	for _, option := range options {
+		option(config)
+	}
+	if config.err != nil {
+		return config.err
+	}
+	if config.tls != nil && config.scheme == "" {
+		config.scheme = "https"
+	}
+
+	na.lock.Lock()
+	defer na.lock.Unlock()
+	client := na.client(host)
+	if config.scheme != "" {
+		client.scheme = config.scheme
+	}
+	if config.basePath != nil {
+		client.basePath = config.basePath
+	}
+	if config.tls != nil {
+		client.httpClient = na.tlsHttpClient(config.tls)
+		client.tls, client.ownsTransport = config.tls, true
+	}
+	if config.limiter != nil {
+		client.limiter = config.limiter
+	}
+	return nil
+}
+
+// split "https://host:port" into the scheme and host
+func splitScheme(host string) (string, string) {
+	if i := strings.Index(host, "://"); i > 0 {
+		return strings.ToLower(host[:i]), strings.TrimSuffix(host[i+3:], "/")
+	}
+	return "", host
+}
+
+func basePath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// copy of the NamedApi's http client using tlsConfig. A custom transport that is not an
+// *http.Transport is replaced by a clone of http.DefaultTransport
+func (na *NamedApi) tlsHttpClient(tlsConfig *tls.Config) http.Client {
+	client := *na.httpClient
+
+	var transport *http.Transport
+	if t, ok := client.Transport.(*http.Transport); ok {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	client.Transport = transport
+	if na.tracingTransport {
+		client.Transport = otelhttp.NewTransport(transport, otelhttp.WithSpanNameFormatter(spanName))
+	}
+	return client
+}
@@ -0,0 +1,113 @@
+package internalApi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ishan27g/internalApi/request"
+	"github.com/stretchr/testify/assert"
+)
+
+// self-signed client certificate written to dir
+func clientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "clientForX"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, certFile, keyFile
+}
+
+func Test_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	cert, certFile, keyFile := clientCert(t, dir)
+
+	var path string
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	s.StartTLS()
+	defer s.Close()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw}), 0600))
+
+	host := strings.TrimPrefix(s.URL, "https://")
+	api := NewNamed("v1", WithTracingHttpClient())
+	api.Add("GetUser", host)
+	api.Add("Health", host)
+
+	// without a client certificate
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, err := api.Get("GetUser", req)
+	assert.Error(t, err)
+
+	assert.NoError(t, api.AddHost(host, WithCAFile(caFile), WithClientCert(certFile, keyFile), WithServerName("example.com")))
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, err = api.Get("GetUser", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "/v1/users", path)
+
+	assert.NoError(t, api.AddHost(host, WithHostBasePath("/")))
+	req, _ = request.NewRequest(context.Background(), "/health", nil)
+	_, err = api.Get("Health", req)
+	assert.NoError(t, err)
+	assert.Equal(t, "/health", path)
+
+	assert.Error(t, api.AddHost(host, WithClientCert(certFile, caFile)))
+}
+
+func Test_Scheme(t *testing.T) {
+	api := NewNamed("v1", WithBasePath("api/v1/"))
+	api.Add("GetUser", "https://localhost:9443")
+	api.Add("AddUser", "localhost:9999")
+
+	var urls []string
+	hook := func(w http.ResponseWriter, r *http.Request) {
+		urls = append(urls, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}
+	api.ExpectHook("GetUser", hook)
+	api.ExpectHook("AddUser", hook)
+
+	req, _ := request.NewRequest(context.Background(), "/users", nil)
+	_, _ = api.Get("GetUser", req)
+	req, _ = request.NewRequest(context.Background(), "/users", nil)
+	_, _ = api.Post("AddUser", req)
+
+	assert.Equal(t, []string{"https://localhost:9443/api/v1/users", "http://localhost:9999/api/v1/users"}, urls)
+}
@@ -0,0 +1,82 @@
+package internalApi
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// fileWatcher polls a file and loads it again when it is modified, until it is closed.
+// Used by FileResolver and ConfigWatcher
+type fileWatcher struct {
+	path string
+	load func() error // reads the modified file
+
+	lock     sync.RWMutex
+	modified time.Time
+	err      error
+
+	quit chan bool
+	once sync.Once
+}
+
+func newFileWatcher(path string, load func() error) *fileWatcher {
+	return &fileWatcher{path: path, load: load, quit: make(chan bool)}
+}
+
+// reload the file if it was modified, a failed load is retried on the next poll
+func (w *fileWatcher) reload() (err error) {
+	defer func() {
+		w.lock.Lock()
+		w.err = err
+		w.lock.Unlock()
+	}()
+
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	w.lock.RLock()
+	unchanged := info.ModTime().Equal(w.modified)
+	w.lock.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	if err := w.load(); err != nil {
+		return err
+	}
+	w.lock.Lock()
+	w.modified = info.ModTime()
+	w.lock.Unlock()
+	return nil
+}
+
+// watch polls the file every interval, onError is called with failed reloads if set
+func (w *fileWatcher) watch(interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.quit:
+			return
+		case <-ticker.C:
+			if err := w.reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// error of the last reload, if it failed
+func (w *fileWatcher) lastErr() error {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.err
+}
+
+func (w *fileWatcher) close() {
+	w.once.Do(func() {
+		close(w.quit)
+	})
+}